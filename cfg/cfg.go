@@ -0,0 +1,126 @@
+package ucfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/metaleap/go-util/dev"
+	"github.com/metaleap/go-util/sys"
+)
+
+//	Loads JSON or JSONC (see `StripJsonc`) configuration files into structs,
+//	reporting all problems as `udev.SrcMsg`s with file path, line and column.
+type Loader struct {
+	//	If `true`, object keys not matching any struct field are reported as errors.
+	DisallowUnknownFields bool
+
+	//	If `true`, `${NAME}` references in string values and `default` tags are kept as-is.
+	NoEnvExpansion bool
+
+	//	If `true`, `default` struct-field tags are ignored.
+	NoDefaults bool
+
+	//	If `true`, files that don't exist are reported as errors instead of being skipped.
+	RequireAll bool
+
+	//	Used for `${NAME}` expansion, defaults to `os.LookupEnv` if `nil`.
+	LookupEnv func(string) (string, bool)
+}
+
+var (
+	//	The `Loader` used by the `Load` function.
+	Default = &Loader{}
+
+	//	The directory path prepended to the system-wide configuration file path returned by `StackFilePaths`.
+	SysConfigDirPath = "/etc"
+)
+
+//	Convenience short-hand for `Default.Load(into, filePaths...)`.
+func Load(into interface{}, filePaths ...string) udev.SrcMsgs {
+	return Default.Load(into, filePaths...)
+}
+
+//	Returns the conventional stack of configuration file paths to pass to `Load`, in order
+//	of increasing precedence: system-wide (in `SysConfigDirPath`), then per-user (in
+//	`usys.UserDataDirPath`), then project-local (in `projDirPath`, omitted if empty).
+func StackFilePaths(appName string, fileName string, projDirPath string) (filePaths []string) {
+	filePaths = []string{
+		filepath.Join(SysConfigDirPath, appName, fileName),
+		filepath.Join(usys.UserDataDirPath(false), appName, fileName),
+	}
+	if len(projDirPath) > 0 {
+		filePaths = append(filePaths, filepath.Join(projDirPath, fileName))
+	}
+	return
+}
+
+//	Applies `default` tags to `into` (unless `me.NoDefaults`), then decodes all specified
+//	JSONC files into it in order, so that values in later files override those in earlier
+//	ones. (Objects and maps are merged key by key, arrays are replaced as a whole.)
+//	Finally, `${NAME}` references in all strings are expanded (unless `me.NoEnvExpansion`).
+//
+//	Missing files are skipped unless `me.RequireAll`. All other problems are returned,
+//	those in file contents with their `Ref`, `Pos1Ln` and `Pos1Ch` set.
+func (me *Loader) Load(into interface{}, filePaths ...string) (msgs udev.SrcMsgs) {
+	if rv := reflect.ValueOf(into); rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return udev.SrcMsgs{&udev.SrcMsg{Msg: "ucfg.Loader.Load: expected a non-nil pointer", Pos1Ln: 1, Pos1Ch: 1}}
+	} else if !me.NoDefaults && rv.Elem().Kind() == reflect.Struct {
+		if err := applyDefaults(rv.Elem(), !me.NoEnvExpansion, me.LookupEnv, false); err != nil {
+			msgs = append(msgs, &udev.SrcMsg{Msg: err.Error(), Pos1Ln: 1, Pos1Ch: 1})
+		}
+	}
+	for _, filePath := range filePaths {
+		if src, err := ioutil.ReadFile(filePath); err != nil {
+			if me.RequireAll || !os.IsNotExist(err) {
+				msgs = append(msgs, &udev.SrcMsg{Msg: err.Error(), Ref: filePath, Pos1Ln: 1, Pos1Ch: 1})
+			}
+		} else if msg := me.decode(into, filePath, src); msg != nil {
+			msgs = append(msgs, msg)
+		}
+	}
+	if !me.NoEnvExpansion {
+		expandEnvIn(reflect.ValueOf(into), me.LookupEnv)
+	}
+	return
+}
+
+//	Decodes the specified JSONC `src` into `into` (without applying defaults or
+//	env expansion), `srcFilePath` is only used for the returned `udev.SrcMsg`.
+func (me *Loader) LoadSrc(into interface{}, srcFilePath string, src []byte) udev.SrcMsgs {
+	if msg := me.decode(into, srcFilePath, src); msg != nil {
+		return udev.SrcMsgs{msg}
+	}
+	return nil
+}
+
+func (me *Loader) decode(into interface{}, srcFilePath string, src []byte) (msg *udev.SrcMsg) {
+	dec := json.NewDecoder(bytes.NewReader(StripJsonc(src)))
+	if me.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	err := dec.Decode(into)
+	if err == nil {
+		if _, errextra := dec.Token(); errextra != io.EOF {
+			err = errors.New("unexpected content after top-level JSON value")
+		}
+	}
+	if err != nil {
+		msg = &udev.SrcMsg{Msg: err.Error(), Ref: srcFilePath}
+		var errsyn *json.SyntaxError
+		var errtype *json.UnmarshalTypeError
+		if errors.As(err, &errsyn) {
+			msg.Pos1Ln, msg.Pos1Ch = offsetLnCh(src, errsyn.Offset-1)
+		} else if errors.As(err, &errtype) {
+			msg.Pos1Ln, msg.Pos1Ch = offsetLnCh(src, errtype.Offset)
+		} else {
+			msg.Pos1Ln, msg.Pos1Ch = offsetLnCh(src, dec.InputOffset())
+		}
+	}
+	return
+}
@@ -0,0 +1,119 @@
+package ucfg
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	//	The struct-field tag key consulted by `ApplyDefaults`.
+	DefaultTagKey = "default"
+
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+//	Sets all zero-valued fields in the struct pointed to by `into` (and in all its nested
+//	structs) that carry a `default` tag (see `DefaultTagKey`) to the value given in that tag.
+//
+//	Supported field types are `string`, `bool`, all integer and float types,
+//	`time.Duration` (in `time.ParseDuration` syntax) and slices of any of those (with
+//	comma-separated tag values). Nested structs are visited, `nil` pointers to structs are not.
+//
+//	If `expandEnv` is `true`, `${NAME}` references in tag values are expanded as per `ExpandEnv`.
+func ApplyDefaults(into interface{}, expandEnv bool) error {
+	rv := reflect.ValueOf(into)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("ucfg.ApplyDefaults: expected a non-nil struct pointer, not %T", into)
+	}
+	return applyDefaults(rv.Elem(), expandEnv, nil, true)
+}
+
+//	`expandStrs` is `false` for `Loader.Load`, which expands all `string`s afterwards anyway.
+func applyDefaults(rv reflect.Value, expandEnv bool, lookupEnv func(string) (string, bool), expandStrs bool) (err error) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField() && err == nil; i++ {
+		field, fv := rt.Field(i), rv.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		if tag, ok := field.Tag.Lookup(DefaultTagKey); ok {
+			if expandEnv && (expandStrs || !isStrs(fv.Type())) {
+				tag = ExpandEnv(tag, lookupEnv)
+			}
+			if isZero(fv) {
+				if err = setFromStr(fv, tag); err != nil {
+					err = fmt.Errorf("%s.%s: bad `%s` tag value %q: %v", rt.Name(), field.Name, DefaultTagKey, tag, err)
+				}
+			}
+		} else if fv.Kind() == reflect.Struct {
+			err = applyDefaults(fv, expandEnv, lookupEnv, expandStrs)
+		} else if fv.Kind() == reflect.Ptr && !fv.IsNil() && fv.Elem().Kind() == reflect.Struct {
+			err = applyDefaults(fv.Elem(), expandEnv, lookupEnv, expandStrs)
+		}
+	}
+	return
+}
+
+func isStrs(rt reflect.Type) bool {
+	return rt.Kind() == reflect.String || (rt.Kind() == reflect.Slice && rt.Elem().Kind() == reflect.String)
+}
+
+func isZero(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
+
+func setFromStr(rv reflect.Value, s string) (err error) {
+	if rv.Type() == durationType {
+		var d time.Duration
+		if d, err = time.ParseDuration(s); err == nil {
+			rv.SetInt(int64(d))
+		}
+		return
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(s)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			rv.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(s, 0, rv.Type().Bits()); err == nil {
+			rv.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		if u, err = strconv.ParseUint(s, 0, rv.Type().Bits()); err == nil {
+			rv.SetUint(u)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(s, rv.Type().Bits()); err == nil {
+			rv.SetFloat(f)
+		}
+	case reflect.Slice:
+		var vals []string
+		if s = strings.TrimSpace(s); len(s) > 0 {
+			vals = strings.Split(s, ",")
+		}
+		sl := reflect.MakeSlice(rv.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err = setFromStr(sl.Index(i), strings.TrimSpace(val)); err != nil {
+				return
+			}
+		}
+		rv.Set(sl)
+	default:
+		err = fmt.Errorf("unsupported field type %s", rv.Type())
+	}
+	return
+}
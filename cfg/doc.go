@@ -0,0 +1,2 @@
+// Go programming helpers for common configuration-loading needs.
+package ucfg
@@ -0,0 +1,83 @@
+package ucfg
+
+import (
+	"os"
+	"reflect"
+	"strings"
+)
+
+//	Replaces all `${NAME}` references in `s` with the value of the environment
+//	variable `NAME`, as looked up by `lookupEnv` (or `os.LookupEnv` if `nil`).
+//
+//	`${NAME:-fallback}` expands to `fallback` if `NAME` is unset or empty.
+//	`$${` is an escape for a literal `${`. A `$` not followed by `{` is kept as-is.
+func ExpandEnv(s string, lookupEnv func(string) (string, bool)) string {
+	if strings.IndexByte(s, '$') < 0 {
+		return s
+	}
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i == len(s)-1 {
+			buf.WriteByte(s[i])
+		} else if strings.HasPrefix(s[i+1:], "${") {
+			buf.WriteString("${")
+			i += 2
+		} else if end := strings.IndexByte(s[i:], '}'); s[i+1] != '{' || end < 0 {
+			buf.WriteByte(s[i])
+		} else {
+			name, fallback := s[i+2:i+end], ""
+			if pos := strings.Index(name, ":-"); pos >= 0 {
+				name, fallback = name[:pos], name[pos+2:]
+			}
+			if val, _ := lookupEnv(name); len(val) > 0 {
+				buf.WriteString(val)
+			} else {
+				buf.WriteString(fallback)
+			}
+			i += end
+		}
+	}
+	return buf.String()
+}
+
+//	Applies `ExpandEnv` to all `string`s reachable from `rv`: struct fields, slice and
+//	array elements, map values and `interface{}`-typed values holding `string`s.
+func expandEnvIn(rv reflect.Value, lookupEnv func(string) (string, bool)) {
+	switch rv.Kind() {
+	case reflect.String:
+		if rv.CanSet() {
+			rv.SetString(ExpandEnv(rv.String(), lookupEnv))
+		}
+	case reflect.Ptr:
+		if !rv.IsNil() {
+			expandEnvIn(rv.Elem(), lookupEnv)
+		}
+	case reflect.Interface:
+		if !rv.IsNil() && rv.CanSet() {
+			val := reflect.New(rv.Elem().Type()).Elem()
+			val.Set(rv.Elem())
+			expandEnvIn(val, lookupEnv)
+			rv.Set(val)
+		}
+	case reflect.Struct:
+		for i := 0; i < rv.NumField(); i++ {
+			if fv := rv.Field(i); fv.CanSet() {
+				expandEnvIn(fv, lookupEnv)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			expandEnvIn(rv.Index(i), lookupEnv)
+		}
+	case reflect.Map:
+		for _, key := range rv.MapKeys() {
+			val := reflect.New(rv.Type().Elem()).Elem()
+			val.Set(rv.MapIndex(key))
+			expandEnvIn(val, lookupEnv)
+			rv.SetMapIndex(key, val)
+		}
+	}
+}
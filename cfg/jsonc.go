@@ -0,0 +1,87 @@
+package ucfg
+
+//	Returns a copy of the specified JSONC `src` that is valid JSON: all `//` line
+//	comments, `/* */` block comments and trailing commas (before a closing `]` or `}`)
+//	are overwritten with spaces. Line breaks are kept and the result has the exact
+//	same length as `src`, so that all byte offsets into it are also valid for `src`.
+func StripJsonc(src []byte) []byte {
+	json := make([]byte, len(src))
+	copy(json, src)
+	stripComments(json)
+	stripTrailingCommas(json)
+	return json
+}
+
+func stripComments(json []byte) {
+	for i, instr := 0, false; i < len(json); i++ {
+		if c := json[i]; instr {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				instr = false
+			}
+		} else if c == '"' {
+			instr = true
+		} else if c == '/' && i < len(json)-1 && json[i+1] == '/' {
+			for ; i < len(json) && json[i] != '\n'; i++ {
+				if json[i] != '\r' {
+					json[i] = ' '
+				}
+			}
+		} else if c == '/' && i < len(json)-1 && json[i+1] == '*' {
+			json[i], json[i+1] = ' ', ' '
+			for i += 2; i < len(json); i++ {
+				if json[i] == '*' && i < len(json)-1 && json[i+1] == '/' {
+					json[i], json[i+1] = ' ', ' '
+					i++
+					break
+				} else if json[i] != '\n' && json[i] != '\r' {
+					json[i] = ' '
+				}
+			}
+		}
+	}
+}
+
+func stripTrailingCommas(json []byte) {
+	lastcomma := -1
+	for i, instr := 0, false; i < len(json); i++ {
+		if c := json[i]; instr {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				instr = false
+			}
+		} else {
+			switch c {
+			case ' ', '\t', '\r', '\n':
+				continue
+			case ']', '}':
+				if lastcomma >= 0 {
+					json[lastcomma] = ' '
+				}
+			case '"':
+				instr = true
+			}
+			if lastcomma = -1; c == ',' {
+				lastcomma = i
+			}
+		}
+	}
+}
+
+//	Returns the 1-based line and (byte-based) column numbers of the specified byte `offset` into `src`.
+func offsetLnCh(src []byte, offset int64) (ln int, ch int) {
+	if offset > int64(len(src)) {
+		offset = int64(len(src))
+	}
+	ln, ch = 1, 1
+	for i := int64(0); i < offset; i++ {
+		if src[i] == '\n' {
+			ln, ch = ln+1, 1
+		} else {
+			ch++
+		}
+	}
+	return
+}
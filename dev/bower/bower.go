@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/url"
+	"strings"
+
+	"github.com/metaleap/go-util"
 )
 
 type BowerFile struct {
@@ -40,6 +43,23 @@ func (me *BowerFile) RepositoryURLParsed() (repoUrl *url.URL) {
 	return
 }
 
+//	Returns the version-range constraint declared for the specified dependency in `me.Dependencies`
+//	(or, failing that, in `me.DevDependencies`). For dependencies given in the `owner/repo#range`
+//	or `url#range` forms, the part following the `#` is parsed. Returns `nil` if `depName` isn't declared.
+func (me *BowerFile) DependencyRange(depName string) (rng *umisc.SemVerRange, err error) {
+	rangestr, ok := me.Dependencies[depName]
+	if !ok {
+		rangestr, ok = me.DevDependencies[depName]
+	}
+	if ok {
+		if i := strings.LastIndex(rangestr, "#"); i >= 0 {
+			rangestr = rangestr[i+1:]
+		}
+		rng, err = umisc.ParseSemVerRange(rangestr)
+	}
+	return
+}
+
 func LoadFromFile(jsonFilePath string, intoStructWithBowerFile interface{}) (err error) {
 	var jsonbytes []byte
 	if jsonbytes, err = ioutil.ReadFile(jsonFilePath); err == nil {
@@ -1,6 +1,8 @@
 package udev
 
 import (
+	"errors"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -30,6 +32,24 @@ func (me SrcMsgs) Len() int           { return len(me) }
 func (me SrcMsgs) Swap(i, j int)      { me[i], me[j] = me[j], me[i] }
 func (me SrcMsgs) Less(i, j int) bool { return me[i].Msg < me[j].Msg }
 
+//	Returns a `ustr.TextTable` listing `me` for terminal output, one row per `SrcMsg`:
+//	its `Ref` (with `Pos1Ln` if set, and then `Pos1Ch` too if set), `Msg` and `Misc`. If `maxMsgWidth`
+//	is greater than 0, longer `Msg` lines are word-wrapped to it.
+func (me SrcMsgs) TextTable(maxMsgWidth int) *ustr.TextTable {
+	table := &ustr.TextTable{MaxWidths: []int{0, maxMsgWidth}, Wrap: true}
+	for _, msg := range me {
+		ref := msg.Ref
+		if msg.Pos1Ln > 0 && len(ref) > 0 {
+			ref += ":" + strconv.Itoa(msg.Pos1Ln)
+			if msg.Pos1Ch > 0 {
+				ref += ":" + strconv.Itoa(msg.Pos1Ch)
+			}
+		}
+		table.AddRow(ref, msg.Msg, msg.Misc)
+	}
+	return table
+}
+
 var (
 	SrcDir string
 )
@@ -95,6 +115,24 @@ func SrcMsgFromLn(ln string) (item *SrcMsg) {
 	return
 }
 
+//	Returns one `SrcMsg` per leaf `error` in `err` (as per `umisc.ErrsFlat`, so all members of
+//	an `umisc.Errs`). For `error`s carrying a path (`*os.PathError` or `*os.LinkError`, also when
+//	wrapped), the `SrcMsg.Ref` is set to that path and `Msg` to the underlying cause.
+func SrcMsgsFromErr(err error) (msgs SrcMsgs) {
+	for _, e := range umisc.ErrsFlat(err) {
+		msg := &SrcMsg{Msg: e.Error(), Pos1Ln: 1, Pos1Ch: 1}
+		var errpath *os.PathError
+		var errlink *os.LinkError
+		if errors.As(e, &errpath) {
+			msg.Ref, msg.Msg, msg.Misc = errpath.Path, errpath.Err.Error(), errpath.Op
+		} else if errors.As(e, &errlink) {
+			msg.Ref, msg.Msg, msg.Misc = errlink.Old, errlink.Err.Error(), errlink.Op+" "+errlink.New
+		}
+		msgs = append(msgs, msg)
+	}
+	return
+}
+
 func SrcMsgsFromLns(lines []string) (msgs SrcMsgs) {
 	for i, _ := range lines {
 		if item := SrcMsgFromLn(lines[i]); item != nil {
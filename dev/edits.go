@@ -0,0 +1,116 @@
+package udev
+
+import (
+	"io/ioutil"
+	"sort"
+
+	"github.com/metaleap/go-util"
+	"github.com/metaleap/go-util/fs"
+	"github.com/metaleap/go-util/str"
+)
+
+//	Returns the minimal text edits turning `src` into `dst`, one `SrcMsg` per changed range: `Ref` is `ref`, `Msg` is
+//	the replacement text, and `Pos1Ln`/`Pos1Ch` to `Pos2Ln`/`Pos2Ch` (exclusive) is the replaced range in `src`,
+//	with columns counted in `colUnit`. Changed lines are first found via `ustr.DiffLines` (or `ustr.DiffLinesPatience`
+//	if `patience`) and, if `charLevel`, then narrowed down to the changed characters via `ustr.DiffChars`.
+func SrcEdits(ref string, src string, dst string, colUnit PosUnit, patience bool, charLevel bool) (edits SrcMsgs) {
+	var ops []ustr.DiffOp
+	if patience {
+		ops = ustr.DiffLinesPatience(src, dst)
+	} else {
+		ops = ustr.DiffLines(src, dst)
+	}
+	lineoffsets := func(s string) (offsets []int) {
+		offsets = []int{0}
+		for _, ln := range ustr.LinesKeepEols(s) {
+			offsets = append(offsets, offsets[len(offsets)-1]+len(ln))
+		}
+		return
+	}
+	srcoffsets, dstoffsets, idx := lineoffsets(src), lineoffsets(dst), NewLineIndex(src)
+	edit := func(srcstart int, srcend int, text string) {
+		msg := &SrcMsg{Ref: ref, Msg: text}
+		idx.SetSrcMsgOffsets(msg, srcstart, srcend, colUnit)
+		edits = append(edits, msg)
+	}
+	for i := 0; i < len(ops); i++ {
+		if ops[i].Kind == ustr.DiffEqual {
+			continue
+		}
+		op := ops[i]
+		if op.Kind == ustr.DiffDelete && i < len(ops)-1 && ops[i+1].Kind == ustr.DiffInsert {
+			i++
+			op.B1, op.B2 = ops[i].B1, ops[i].B2
+		}
+		s1, s2, d1, d2 := srcoffsets[op.A1], srcoffsets[op.A2], dstoffsets[op.B1], dstoffsets[op.B2]
+		if !(charLevel && s2 > s1 && d2 > d1) {
+			edit(s1, s2, dst[d1:d2])
+			continue
+		}
+		charops := ustr.DiffChars(src[s1:s2], dst[d1:d2])
+		for j := 0; j < len(charops); j++ {
+			if cop := charops[j]; cop.Kind != ustr.DiffEqual {
+				if cop.Kind == ustr.DiffDelete && j < len(charops)-1 && charops[j+1].Kind == ustr.DiffInsert {
+					j++
+					cop.B1, cop.B2 = charops[j].B1, charops[j].B2
+				}
+				edit(s1+cop.A1, s1+cop.A2, dst[d1+cop.B1:d1+cop.B2])
+			}
+		}
+	}
+	return
+}
+
+//	Applies `edits` (such as those returned by `SrcEdits`, disregarding their `Ref`s) to `src`, with columns
+//	counted in `colUnit`. All edit ranges refer to the original `src`, and must not overlap one another.
+func ApplySrcEdits(src string, edits SrcMsgs, colUnit PosUnit) (string, error) {
+	type edit struct {
+		start, end int
+		text       string
+	}
+	idx, sorted := NewLineIndex(src), make([]edit, 0, len(edits))
+	for _, msg := range edits {
+		start, end := idx.SrcMsgOffsets(msg, colUnit)
+		if end < start {
+			start, end = end, start
+		}
+		sorted = append(sorted, edit{start, end, msg.Msg})
+	}
+	sort.SliceStable(sorted, func(i int, j int) bool { return sorted[i].start < sorted[j].start })
+	buf, pos := make([]byte, 0, len(src)), 0
+	for _, e := range sorted {
+		if e.start < pos {
+			return "", umisc.E("overlapping edits at byte offset " + ustr.FromInt(e.start))
+		}
+		buf = append(append(buf, src[pos:e.start]...), e.text...)
+		pos = e.end
+	}
+	return string(append(buf, src[pos:]...)), nil
+}
+
+//	Applies `edits` (as per `ApplySrcEdits`) to the files at their `Ref`s, each of which gets rewritten via
+//	`ufs.WriteTextFileAtomic` with `opts`. Edits are grouped by file, files without edits remain untouched.
+func ApplySrcEditsToFiles(edits SrcMsgs, colUnit PosUnit, opts *ufs.AtomicWriteOptions) error {
+	var refs []string
+	perref := map[string]SrcMsgs{}
+	for _, msg := range edits {
+		if _, ok := perref[msg.Ref]; !ok {
+			refs = append(refs, msg.Ref)
+		}
+		perref[msg.Ref] = append(perref[msg.Ref], msg)
+	}
+	for _, ref := range refs {
+		data, err := ioutil.ReadFile(ref)
+		if err != nil {
+			return err
+		}
+		src, err := ApplySrcEdits(string(data), perref[ref], colUnit)
+		if err != nil {
+			return umisc.E(ref + ": " + err.Error())
+		}
+		if err = ufs.WriteTextFileAtomic(ref, src, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
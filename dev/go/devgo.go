@@ -5,12 +5,17 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/metaleap/go-util"
 	"github.com/metaleap/go-util/fs"
+	"github.com/metaleap/go-util/log"
 	"github.com/metaleap/go-util/run"
 	"github.com/metaleap/go-util/slice"
 )
 
 var (
+	//	The `ulog.Logger` that this package reports to.
+	Log = ulog.Default.Sub("udevgo")
+
 	GoVersion      string
 	GoVersionShort string
 	GoPaths        []string
@@ -131,6 +136,12 @@ func HasGoDevEnv() bool {
 	return true
 }
 
+//	Returns `GoVersion` (as obtained by `HasGoDevEnv`) parsed via `umisc.ParseSemVerLoose`,
+//	for comparisons or `umisc.SemVerRange` matching that the `GoVersionShort` string doesn't allow for.
+func GoSemVer() (umisc.SemVer, error) {
+	return umisc.ParseSemVerLoose(GoVersion)
+}
+
 //	Returns all paths listed in the `GOPATH` environment variable, for users who don't care about calling HasGoDevEnv.
 func AllGoPaths() []string {
 	if len(GoPaths) == 0 {
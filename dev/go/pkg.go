@@ -6,6 +6,7 @@ import (
 	"go/build"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -323,8 +324,10 @@ func RefreshPkgs() error {
 	pkgsbydir, pkgsbyimp, pkgserrs := map[string]*Pkg{}, map[string]*Pkg{}, []*Pkg{}
 
 	if cmdout, cmderr, err := urun.CmdExec("go", "list", "-e", "-json", "all"); err != nil {
+		Log.Debug("RefreshPkgs: go list failed", "err", err)
 		return err
 	} else if cmderr != "" && !ustr.Pref(strings.ToLower(cmderr), "warning: ") {
+		Log.Debug("RefreshPkgs: go list failed", "stderr", cmderr)
 		return errors.New(cmderr)
 	} else if jsonobjstrs := ustr.Split(ustr.Trim(cmdout), "}\n{"); len(jsonobjstrs) > 0 {
 		jsonobjstrs[0] = jsonobjstrs[0][1:]
@@ -333,6 +336,7 @@ func RefreshPkgs() error {
 		for _, jsonobjstr := range jsonobjstrs {
 			var pkg Pkg
 			if err := json.Unmarshal([]byte("{"+jsonobjstr+"}"), &pkg); err != nil {
+				Log.Debug("RefreshPkgs: bad go list output", "err", err)
 				return err
 			} else {
 				if runtime.GOOS == "windows" {
@@ -365,6 +369,7 @@ func RefreshPkgs() error {
 		}
 		ShortenImpPaths = strings.NewReplacer(repls...)
 
+		Log.Debug("RefreshPkgs: done", "pkgs", len(pkgsbyimp), "pkgsWithErrs", len(pkgserrs))
 		pkgsMutex.Lock()
 		defer func() { pkgsMutex.Unlock(); go pkgAfterRefreshUpdateGuruScopeExcls() }()
 		PkgsByDir, PkgsByImP, PkgsErrs = pkgsbydir, pkgsbyimp, pkgserrs
@@ -429,6 +434,22 @@ func PkgImpPathsToNamesInLn(ln string, curPkgDir string) string {
 	return ln
 }
 
+//	Returns the import paths of all `PkgsByImP` that fuzzy-match `query`
+//	(as per `ustr.FuzzyRank`), best matches first.
+func PkgsFuzzy(query string) (pkgImpPaths []string) {
+	if pkgs := PkgsByImP; pkgs != nil {
+		cands := make([]string, 0, len(pkgs))
+		for imppath := range pkgs {
+			cands = append(cands, imppath)
+		}
+		sort.Strings(cands)
+		for _, match := range ustr.FuzzyRank(query, cands) {
+			pkgImpPaths = append(pkgImpPaths, match.Candidate)
+		}
+	}
+	return
+}
+
 func PkgsByName(name string) (pkgImpPaths []string) {
 	if pkgs := PkgsByImP; pkgs != nil {
 		for _, pkg := range pkgs {
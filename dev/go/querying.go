@@ -63,6 +63,22 @@ func (me *Guru) Matches(pM *gurujson.DescribeMember, lowerCaseQuery string) bool
 		strings.Contains(strings.ToLower(pM.Value), lowerCaseQuery)
 }
 
+//	Returns those `me.Package.Members` whose `Name` fuzzy-matches `query` (as per `ustr.FuzzyRank`),
+//	best matches first, together with their `ustr.FuzzyMatch`es (for highlighting) in the same order.
+func (me *Guru) FuzzyMembers(query string) (members []*gurujson.DescribeMember, matches []ustr.FuzzyMatch) {
+	names := make([]string, len(me.Package.Members))
+	for i, pm := range me.Package.Members {
+		names[i] = pm.Name
+	}
+	if matches = ustr.FuzzyRank(query, names); len(matches) > 0 {
+		members = make([]*gurujson.DescribeMember, len(matches))
+		for i := range matches {
+			members[i] = me.Package.Members[matches[i].Index]
+		}
+	}
+	return
+}
+
 var (
 	GuruScopes        string
 	GuruScopeExclPkgs = map[string]bool{}
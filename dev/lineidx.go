@@ -0,0 +1,268 @@
+package udev
+
+import (
+	"io/ioutil"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+//	The unit in which `LineIndex` offsets and columns are counted.
+type PosUnit int
+
+const (
+	//	Bytes of UTF-8, as used by Go tooling (such as the `bytepos` arguments in `udevgo`).
+	PosBytes PosUnit = iota
+
+	//	Unicode code points (`rune`s).
+	PosRunes
+
+	//	UTF-16 code units, as used by JS and by LSP clients.
+	PosUtf16
+)
+
+func (me PosUnit) len(s string) (n int) {
+	switch me {
+	case PosRunes:
+		n = utf8.RuneCountInString(s)
+	case PosUtf16:
+		for _, r := range s {
+			if n++; r >= 0x10000 {
+				n++
+			}
+		}
+	default:
+		n = len(s)
+	}
+	return
+}
+
+//	Returns the byte length of the longest prefix of `s` that is at most `n` units long.
+func (me PosUnit) prefixLen(s string, n int) int {
+	if n <= 0 {
+		return 0
+	} else if me == PosBytes {
+		if n > len(s) {
+			return len(s)
+		}
+		return n
+	}
+	units := 0
+	for i, r := range s {
+		if units++; me == PosUtf16 && r >= 0x10000 {
+			units++
+		}
+		if units > n {
+			return i
+		}
+	}
+	return len(s)
+}
+
+//	An index of the line starts in a source text, converting between byte offsets and lines / columns
+//	(or offsets) counted in any `PosUnit`. It can be updated incrementally via `Edit` and `EditPos`.
+//
+//	Lines and columns are 1-based as in `SrcMsg` (so LSP's 0-based positions need adjusting by 1),
+//	offsets are 0-based. Out-of-range lines, columns and offsets are clamped to the nearest valid position.
+type LineIndex struct {
+	src        string
+	lineStarts []int   // byte offsets
+	unitStarts [][]int // per `PosUnit` other than `PosBytes` (lazily): offsets of `lineStarts`
+}
+
+//	Returns a new `LineIndex` for `src`.
+func NewLineIndex(src string) (me *LineIndex) {
+	me = &LineIndex{src: src, lineStarts: []int{0}}
+	me.lineStarts = append(me.lineStarts, lineIdxStarts(src, 0)...)
+	return
+}
+
+//	Returns a new `LineIndex` for the contents of the specified file.
+func NewLineIndexFromFile(filePath string) (*LineIndex, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewLineIndex(string(data)), nil
+}
+
+func lineIdxStarts(s string, offset int) (starts []int) {
+	for i := strings.IndexByte(s, '\n'); i >= 0; i = strings.IndexByte(s, '\n') {
+		offset += i + 1
+		starts, s = append(starts, offset), s[i+1:]
+	}
+	return
+}
+
+//	Returns the current source text, including all `Edit`s.
+func (me *LineIndex) Src() string {
+	return me.src
+}
+
+//	Returns the number of lines: 1 more than the number of `\n`s in `Src`.
+func (me *LineIndex) NumLines() int {
+	return len(me.lineStarts)
+}
+
+//	Returns the text of the specified line, without its terminating `\n` (but with any `\r`).
+func (me *LineIndex) Line(ln int) string {
+	start, end := me.lineBounds(me.clampLine(ln))
+	return me.src[start:end]
+}
+
+func (me *LineIndex) clampLine(ln int) int {
+	if ln < 1 {
+		return 1
+	} else if ln > len(me.lineStarts) {
+		return len(me.lineStarts)
+	}
+	return ln
+}
+
+func (me *LineIndex) clampOffset(offset int) int {
+	if offset < 0 {
+		return 0
+	} else if offset > len(me.src) {
+		return len(me.src)
+	}
+	return offset
+}
+
+//	Returns the byte offsets of the start and end (excluding the `\n`) of line `ln` (which must be valid).
+func (me *LineIndex) lineBounds(ln int) (start int, end int) {
+	if start, end = me.lineStarts[ln-1], len(me.src); ln < len(me.lineStarts) {
+		end = me.lineStarts[ln] - 1
+	}
+	return
+}
+
+//	Returns the 1-based line and column (counted in `colUnit`) of the specified 0-based `byteOffset`.
+func (me *LineIndex) Pos(byteOffset int, colUnit PosUnit) (ln int, col int) {
+	byteOffset = me.clampOffset(byteOffset)
+	ln = sort.Search(len(me.lineStarts), func(i int) bool { return me.lineStarts[i] > byteOffset })
+	return ln, 1 + colUnit.len(me.src[me.lineStarts[ln-1]:byteOffset])
+}
+
+//	Returns the 0-based byte offset of the specified 1-based line `ln` and column `col` (counted in `colUnit`).
+func (me *LineIndex) Offset(ln int, col int, colUnit PosUnit) int {
+	start, end := me.lineBounds(me.clampLine(ln))
+	return start + colUnit.prefixLen(me.src[start:end], col-1)
+}
+
+//	Converts the 0-based `byteOffset` into an offset counted in `unit`.
+func (me *LineIndex) OffsetIn(byteOffset int, unit PosUnit) int {
+	if unit == PosBytes {
+		return me.clampOffset(byteOffset)
+	}
+	ln, _ := me.Pos(byteOffset, PosBytes)
+	return me.unitLineStarts(unit)[ln-1] + unit.len(me.src[me.lineStarts[ln-1]:me.clampOffset(byteOffset)])
+}
+
+//	Converts the 0-based `offset` counted in `unit` into a byte offset.
+func (me *LineIndex) ByteOffset(offset int, unit PosUnit) int {
+	if unit == PosBytes {
+		return me.clampOffset(offset)
+	}
+	starts := me.unitLineStarts(unit)
+	ln := sort.Search(len(starts), func(i int) bool { return starts[i] > offset })
+	if ln < 1 {
+		return 0
+	}
+	return me.lineStarts[ln-1] + unit.prefixLen(me.src[me.lineStarts[ln-1]:], offset-starts[ln-1])
+}
+
+func (me *LineIndex) unitLineStarts(unit PosUnit) []int {
+	if len(me.unitStarts) <= int(unit) {
+		me.unitStarts = append(me.unitStarts, make([][]int, 1+int(unit)-len(me.unitStarts))...)
+	}
+	if me.unitStarts[unit] == nil {
+		starts := make([]int, len(me.lineStarts))
+		for ln := 1; ln < len(me.lineStarts); ln++ {
+			starts[ln] = starts[ln-1] + unit.len(me.src[me.lineStarts[ln-1]:me.lineStarts[ln]])
+		}
+		me.unitStarts[unit] = starts
+	}
+	return me.unitStarts[unit]
+}
+
+//	Replaces the bytes from `byteStart` up to (excluding) `byteEnd` with `text`, updating the index incrementally.
+func (me *LineIndex) Edit(byteStart int, byteEnd int, text string) {
+	if byteStart, byteEnd = me.clampOffset(byteStart), me.clampOffset(byteEnd); byteEnd < byteStart {
+		byteStart, byteEnd = byteEnd, byteStart
+	}
+	me.src = me.src[:byteStart] + text + me.src[byteEnd:]
+	me.unitStarts = nil
+
+	delta := len(text) - (byteEnd - byteStart)
+	keep := sort.Search(len(me.lineStarts), func(i int) bool { return me.lineStarts[i] > byteStart })
+	after := sort.Search(len(me.lineStarts), func(i int) bool { return me.lineStarts[i] > byteEnd })
+	tail := me.lineStarts[after:]
+	for i := range tail {
+		tail[i] += delta
+	}
+	me.lineStarts = append(append(me.lineStarts[:keep:keep], lineIdxStarts(text, byteStart)...), tail...)
+}
+
+//	Like `Edit`, but with the range specified as 1-based lines and columns (counted in `colUnit`),
+//	as in LSP's `textDocument/didChange` notifications (after adding 1 to their positions).
+func (me *LineIndex) EditPos(ln1 int, col1 int, ln2 int, col2 int, colUnit PosUnit, text string) {
+	me.Edit(me.Offset(ln1, col1, colUnit), me.Offset(ln2, col2, colUnit), text)
+}
+
+//	Converts the `Pos1Ch` and `Pos2Ch` columns of all `msgs` from `from` to `to` units, for all `msgs`
+//	with the specified `ref` (or if `ref` is empty, all `msgs`). Lines remain unchanged.
+func (me *LineIndex) ConvertSrcMsgs(msgs SrcMsgs, ref string, from PosUnit, to PosUnit) {
+	for _, msg := range msgs {
+		if len(ref) == 0 || msg.Ref == ref {
+			if msg.Pos1Ln > 0 && msg.Pos1Ch > 0 {
+				_, msg.Pos1Ch = me.Pos(me.Offset(msg.Pos1Ln, msg.Pos1Ch, from), to)
+			}
+			if msg.Pos2Ln > 0 && msg.Pos2Ch > 0 {
+				_, msg.Pos2Ch = me.Pos(me.Offset(msg.Pos2Ln, msg.Pos2Ch, from), to)
+			}
+		}
+	}
+}
+
+//	Returns the 0-based byte offsets of `msg`'s `Pos1Ln`/`Pos1Ch` and `Pos2Ln`/`Pos2Ch`
+//	(with columns counted in `colUnit`). If `msg` has no `Pos2Ln`, `byteOffset2` equals `byteOffset1`.
+func (me *LineIndex) SrcMsgOffsets(msg *SrcMsg, colUnit PosUnit) (byteOffset1 int, byteOffset2 int) {
+	byteOffset1 = me.Offset(msg.Pos1Ln, msg.Pos1Ch, colUnit)
+	if byteOffset2 = byteOffset1; msg.Pos2Ln > 0 {
+		byteOffset2 = me.Offset(msg.Pos2Ln, msg.Pos2Ch, colUnit)
+	}
+	return
+}
+
+//	Sets `msg`'s `Pos1Ln`/`Pos1Ch` and `Pos2Ln`/`Pos2Ch` from the specified 0-based byte offsets
+//	(with columns counted in `colUnit`). If `byteOffset2` is negative, `Pos2Ln` and `Pos2Ch` are set to 0.
+func (me *LineIndex) SetSrcMsgOffsets(msg *SrcMsg, byteOffset1 int, byteOffset2 int, colUnit PosUnit) {
+	msg.Pos1Ln, msg.Pos1Ch = me.Pos(byteOffset1, colUnit)
+	if msg.Pos2Ln, msg.Pos2Ch = 0, 0; byteOffset2 >= 0 {
+		msg.Pos2Ln, msg.Pos2Ch = me.Pos(byteOffset2, colUnit)
+	}
+}
+
+//	Converts the `Pos1Ch` and `Pos2Ch` columns of all `msgs` from `from` to `to` units, using for
+//	each `SrcMsg.Ref` the `LineIndex` returned by `indexOf`. If `indexOf` is `nil`, each `Ref`
+//	file is read once via `NewLineIndexFromFile`. `SrcMsg`s without a `LineIndex` remain unchanged.
+func ConvertSrcMsgs(msgs SrcMsgs, from PosUnit, to PosUnit, indexOf func(ref string) *LineIndex) {
+	if from == to {
+		return
+	}
+	indices := map[string]*LineIndex{}
+	for _, msg := range msgs {
+		idx, ok := indices[msg.Ref]
+		if !ok {
+			if indexOf != nil {
+				idx = indexOf(msg.Ref)
+			} else {
+				idx, _ = NewLineIndexFromFile(msg.Ref)
+			}
+			indices[msg.Ref] = idx
+		}
+		if idx != nil {
+			idx.ConvertSrcMsgs(SrcMsgs{msg}, "", from, to)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package udevps
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/metaleap/go-util/str"
+)
 
 type CoreAnnotated struct {
 	Annotation CoreAnnotation `json:"annotation"`
@@ -103,3 +107,14 @@ type CoreSourceSpan struct {
 	Start []int  `json:"start"`
 	End   []int  `json:"end"`
 }
+
+//	Returns per output line (index 0 for line 1) the `CoreSourceSpan` that produced it, for a `buf` into which
+//	code was emitted between `buf.PushSrc(span)` and `buf.PopSrc()` calls with `*CoreSourceSpan`s.
+func CoreSourceSpansPerLine(buf *ustr.Buffer) (spans []*CoreSourceSpan) {
+	srcmap := buf.SrcMap()
+	spans = make([]*CoreSourceSpan, len(srcmap))
+	for i, src := range srcmap {
+		spans[i], _ = src.(*CoreSourceSpan)
+	}
+	return
+}
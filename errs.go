@@ -0,0 +1,81 @@
+package umisc
+
+import (
+	"strconv"
+	"strings"
+)
+
+//	An `error` aggregating any number of `error`s, such as those returned as `[]error` by
+//	`ufs.DirWalker.Walk` or `ufs.Watcher.WatchIn` (see `ErrsOf`). `errors.Is` and `errors.As`
+//	consider all members. To convert to `udev.SrcMsgs`, see `udev.SrcMsgsFromErr`.
+type Errs []error
+
+//	Returns `nil` if `errs` contains no non-`nil` `error`s, the only one if it contains exactly one,
+//	or else an `Errs` of all non-`nil` `error`s in `errs` (with nested `Errs` flattened).
+//
+//	This is the adapter for handing back a single `error` from the many `[]error`-returning funcs,
+//	as in `umisc.ErrsOf(ufs.WalkAllDirs(dirpath, visitor))`.
+func ErrsOf(errs []error) error {
+	var all Errs
+	all.Add(errs...)
+	return all.Err()
+}
+
+//	Appends all non-`nil` `errs` to `me`, flattening any `Errs` among them.
+func (me *Errs) Add(errs ...error) {
+	for _, err := range errs {
+		if sub, ok := err.(Errs); ok {
+			me.Add(sub...)
+		} else if err != nil {
+			*me = append(*me, err)
+		}
+	}
+}
+
+//	Returns `nil` if `me` is empty, its only `error` if it has one, otherwise `me`.
+func (me Errs) Err() error {
+	switch len(me) {
+	case 0:
+		return nil
+	case 1:
+		return me[0]
+	}
+	return me
+}
+
+//	Implements `error`. For a single `error`, returns its message as-is; otherwise returns the
+//	count followed by one numbered line per `error`, with multi-line messages indented.
+func (me Errs) Error() string {
+	switch len(me) {
+	case 0:
+		return "no errors"
+	case 1:
+		return me[0].Error()
+	}
+	var buf strings.Builder
+	buf.WriteString(strconv.Itoa(len(me)) + " errors:")
+	for i, err := range me {
+		buf.WriteString("\n\t" + strconv.Itoa(i+1) + ". ")
+		buf.WriteString(strings.Replace(err.Error(), "\n", "\n\t   ", -1))
+	}
+	return buf.String()
+}
+
+//	Returns all `Errs` for `errors.Is` and `errors.As`.
+func (me Errs) Unwrap() []error {
+	return me
+}
+
+//	Returns all leaf `error`s in `err`: if `err` (or any of its members, recursively) has an
+//	`Unwrap() []error` method (as `Errs` and the results of `errors.Join` do), its members
+//	are returned instead of itself. Returns `nil` for a `nil` `err`.
+func ErrsFlat(err error) (errs []error) {
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, sub := range multi.Unwrap() {
+			errs = append(errs, ErrsFlat(sub)...)
+		}
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+	return
+}
@@ -0,0 +1,568 @@
+package ufs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ulikunitz/xz"
+
+	"github.com/metaleap/go-util"
+	"github.com/metaleap/go-util/str"
+)
+
+//	The archive formats supported by `CreateArchive`, `ExtractArchive` and friends.
+type ArchiveFormat int
+
+const (
+	//	Determined from the archive's file name (see `ArchiveFormatOf`) or else, when reading, from its contents.
+	ArchiveAuto ArchiveFormat = iota
+
+	//	`.zip`
+	ArchiveZip
+
+	//	`.tar`
+	ArchiveTar
+
+	//	`.tar.gz` or `.tgz`
+	ArchiveTarGz
+
+	//	`.tar.xz` or `.txz`
+	ArchiveTarXz
+)
+
+//	Returns the `ArchiveFormat` indicated by the extension of `fileName`, or `ArchiveAuto` if it isn't a known one.
+func ArchiveFormatOf(fileName string) ArchiveFormat {
+	switch name := strings.ToLower(fileName); {
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveZip
+	case strings.HasSuffix(name, ".tar"):
+		return ArchiveTar
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		return ArchiveTarGz
+	case strings.HasSuffix(name, ".tar.xz") || strings.HasSuffix(name, ".txz"):
+		return ArchiveTarXz
+	}
+	return ArchiveAuto
+}
+
+//	Returns for example `tar.gz`, or `auto` for `ArchiveAuto`.
+func (me ArchiveFormat) String() string {
+	switch me {
+	case ArchiveZip:
+		return "zip"
+	case ArchiveTar:
+		return "tar"
+	case ArchiveTarGz:
+		return "tar.gz"
+	case ArchiveTarXz:
+		return "tar.xz"
+	}
+	return "auto"
+}
+
+//	Options for `CreateArchive`, `ExtractArchive` and friends. All fields are optional.
+type ArchiveOptions struct {
+	//	Defaults to `ArchiveAuto`.
+	Format ArchiveFormat
+
+	//	If not empty, only entries whose slash-separated relative paths (when extracting: after `StripComponents`)
+	//	match these `ustr.Matcher` patterns are archived or extracted, such as `"**/*.go", "!vendor/**"`.
+	//	When creating, directories are archived as entries of their own only if they match, too (but always walked into).
+	Patterns []string
+
+	//	When extracting, the number of leading path components removed from all entry paths (as with `tar --strip-components`).
+	//	Entries with no more components than that are skipped.
+	StripComponents int
+
+	//	When creating, files and directories it ignores are not archived.
+	Ignore *Ignorer
+
+	//	When creating, stores `0644` (`0755` for directories and executables) instead of the actual permissions.
+	//	When extracting, creates files with those instead of the archived ones.
+	IgnorePerms bool
+
+	//	When extracting, leaves modification times at the time of extraction instead of setting the archived ones.
+	IgnoreTimes bool
+}
+
+//	Archives the contents of `srcDirPath` (not the directory itself) to `archiveFilePath` via `SaveToFileAtomic`, streaming
+//	files one at a time. If `opts.Format` is `ArchiveAuto`, it's determined from `archiveFilePath` via `ArchiveFormatOf`.
+//	Symbolic links are archived as such. If `archiveFilePath` is inside `srcDirPath`, it isn't archived itself.
+func CreateArchive(archiveFilePath string, srcDirPath string, opts *ArchiveOptions) (err error) {
+	var o ArchiveOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Format == ArchiveAuto {
+		if o.Format = ArchiveFormatOf(archiveFilePath); o.Format == ArchiveAuto {
+			return umisc.E(archiveFilePath + ": unknown archive format")
+		}
+	}
+	archiveFilePath, _ = filepath.Abs(archiveFilePath)
+	skip := func(filePath string) bool {
+		abs, _ := filepath.Abs(filePath)
+		return abs == archiveFilePath || (filepath.Dir(abs) == filepath.Dir(archiveFilePath) &&
+			strings.HasPrefix(filepath.Base(abs), "."+filepath.Base(archiveFilePath)+".tmp")) // see `SaveToFileAtomic`
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeArchive(pw, srcDirPath, &o, skip))
+	}()
+	err = SaveToFileAtomic(pr, archiveFilePath, nil)
+	pr.CloseWithError(err) // in case `SaveToFileAtomic` failed before reading everything
+	return
+}
+
+//	Like `CreateArchive`, but writes the archive to `w` as it goes. `opts.Format` must not be `ArchiveAuto`.
+func WriteArchive(w io.Writer, srcDirPath string, opts *ArchiveOptions) error {
+	var o ArchiveOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Format == ArchiveAuto {
+		return umisc.E("WriteArchive: no archive format specified")
+	}
+	return writeArchive(w, srcDirPath, &o, nil)
+}
+
+func writeArchive(w io.Writer, srcDirPath string, opts *ArchiveOptions, skip func(string) bool) (err error) {
+	var add func(name string, info os.FileInfo, link string, src io.Reader) error
+	var finish func() error
+	switch opts.Format {
+	case ArchiveZip:
+		zw := zip.NewWriter(w)
+		add, finish = func(name string, info os.FileInfo, link string, src io.Reader) (err error) {
+			var hdr *zip.FileHeader
+			if hdr, err = zip.FileInfoHeader(info); err == nil {
+				if hdr.Name = name; info.Mode().IsRegular() {
+					hdr.Method = zip.Deflate
+				}
+				var dst io.Writer
+				if dst, err = zw.CreateHeader(hdr); err == nil && link != "" {
+					_, err = io.WriteString(dst, link)
+				} else if err == nil && src != nil {
+					_, err = io.Copy(dst, src)
+				}
+			}
+			return
+		}, zw.Close
+	case ArchiveTar, ArchiveTarGz, ArchiveTarXz:
+		var compressor io.WriteCloser
+		if opts.Format == ArchiveTarGz {
+			compressor = gzip.NewWriter(w)
+		} else if opts.Format == ArchiveTarXz {
+			if compressor, err = xz.NewWriter(w); err != nil {
+				return
+			}
+		}
+		tw := tar.NewWriter(w)
+		if compressor != nil {
+			tw = tar.NewWriter(compressor)
+		}
+		add, finish = func(name string, info os.FileInfo, link string, src io.Reader) (err error) {
+			var hdr *tar.Header
+			if hdr, err = tar.FileInfoHeader(info, link); err == nil {
+				hdr.Name, hdr.Uname, hdr.Gname = name, "", ""
+				if err = tw.WriteHeader(hdr); err == nil && src != nil {
+					_, err = io.Copy(tw, src)
+				}
+			}
+			return
+		}, func() (err error) {
+			if err = tw.Close(); err == nil && compressor != nil {
+				err = compressor.Close()
+			}
+			return
+		}
+	default:
+		return umisc.E("unknown archive format: " + opts.Format.String())
+	}
+
+	var matcher ustr.Matcher
+	matcher.AddPatterns(opts.Patterns...)
+	visitor := func(fullPath string, entry os.DirEntry) (keepWalking bool) {
+		if skip != nil && skip(fullPath) {
+			return true
+		}
+		rel, _ := filepath.Rel(srcDirPath, fullPath)
+		name, isdir := filepath.ToSlash(rel), entry.IsDir()
+		if len(opts.Patterns) > 0 && !matcher.IsMatch(name) {
+			return true
+		}
+		var info os.FileInfo
+		if info, err = entry.Info(); err != nil {
+			return false
+		}
+		if opts.IgnorePerms {
+			info = &archiveFileInfo{FileInfo: info, mode: (info.Mode() &^ os.ModePerm) | archivePerm(info.Mode(), isdir)}
+		}
+		if isdir {
+			err = add(name+"/", info, "", nil)
+		} else if info.Mode()&os.ModeSymlink != 0 {
+			var link string
+			if link, err = os.Readlink(fullPath); err == nil {
+				err = add(name, info, link, nil)
+			}
+		} else if info.Mode().IsRegular() {
+			var file *os.File
+			if file, err = os.Open(fullPath); err == nil {
+				err = add(name, info, "", file)
+				file.Close()
+			}
+		}
+		return err == nil
+	}
+	walker := NewDirWalker(true, nil, nil)
+	walker.VisitSelf, walker.Ignore, walker.BreakOnError = false, opts.Ignore, true
+	walker.DirEntryVisitor, walker.FileEntryVisitor = visitor, visitor
+	if errwalk := walker.WalkErr(srcDirPath); err == nil {
+		err = errwalk
+	}
+	if errfinish := finish(); err == nil {
+		err = errfinish
+	}
+	return
+}
+
+//	Extracts the archive at `archiveFilePath` into `dstDirPath`, creating it if need be. If `opts.Format` is `ArchiveAuto`,
+//	it's determined from `archiveFilePath` via `ArchiveFormatOf`, or else from its contents. Entries are streamed
+//	to disk one at a time. Entries whose paths (or symbolic link targets) would end up outside of `dstDirPath`
+//	(such as `../../etc/passwd`, the "zip-slip") cause an `error` before anything gets written for them.
+//	Existing files are overwritten, but never written through: existing symbolic links are replaced, not followed.
+func ExtractArchive(archiveFilePath string, dstDirPath string, opts *ArchiveOptions) (err error) {
+	var o ArchiveOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Format == ArchiveAuto {
+		o.Format = ArchiveFormatOf(archiveFilePath)
+	}
+	if o.Format == ArchiveZip {
+		var zr *zip.ReadCloser
+		if zr, err = zip.OpenReader(archiveFilePath); err == nil {
+			defer zr.Close()
+			err = extractZip(&zr.Reader, dstDirPath, &o)
+		}
+		return
+	}
+	var file *os.File
+	if file, err = os.Open(archiveFilePath); err == nil {
+		defer file.Close()
+		err = readArchive(file, dstDirPath, &o)
+	}
+	return
+}
+
+//	Like `ExtractArchive`, but reads the archive from `r`, such as a download in progress. If `opts.Format` is `ArchiveAuto`,
+//	it's determined from the contents. Zip archives (which can't be read sequentially) are first copied to a temporary file.
+func ReadArchive(r io.Reader, dstDirPath string, opts *ArchiveOptions) error {
+	var o ArchiveOptions
+	if opts != nil {
+		o = *opts
+	}
+	return readArchive(r, dstDirPath, &o)
+}
+
+func readArchive(r io.Reader, dstDirPath string, opts *ArchiveOptions) (err error) {
+	if opts.Format == ArchiveAuto {
+		br := bufio.NewReader(r)
+		magic, _ := br.Peek(262)
+		switch r = br; {
+		case bytes.HasPrefix(magic, []byte("PK\x03\x04")) || bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+			opts.Format = ArchiveZip
+		case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+			opts.Format = ArchiveTarGz
+		case bytes.HasPrefix(magic, []byte("\xfd7zXZ\x00")):
+			opts.Format = ArchiveTarXz
+		case len(magic) == 262 && string(magic[257:]) == "ustar":
+			opts.Format = ArchiveTar
+		default:
+			return umisc.E("unknown archive format")
+		}
+	}
+
+	switch opts.Format {
+	case ArchiveZip:
+		var tmpfile *os.File
+		if tmpfile, err = ioutil.TempFile("", "ufs-archive-*.zip"); err != nil {
+			return
+		}
+		defer os.Remove(tmpfile.Name())
+		defer tmpfile.Close()
+		var size int64
+		if size, err = io.Copy(tmpfile, r); err == nil {
+			var zr *zip.Reader
+			if zr, err = zip.NewReader(tmpfile, size); err == nil {
+				err = extractZip(zr, dstDirPath, opts)
+			}
+		}
+		return
+	case ArchiveTarGz:
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(r); err != nil {
+			return
+		}
+		defer gz.Close()
+		r = gz
+	case ArchiveTarXz:
+		if r, err = xz.NewReader(r); err != nil {
+			return
+		}
+	case ArchiveTar:
+	default:
+		return umisc.E("unknown archive format: " + opts.Format.String())
+	}
+
+	var x *archiveExtractor
+	if x, err = newArchiveExtractor(dstDirPath, opts); err != nil {
+		return
+	}
+	tr := tar.NewReader(r)
+	for {
+		var hdr *tar.Header
+		if hdr, err = tr.Next(); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			break
+		}
+		info := hdr.FileInfo()
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeRegA, tar.TypeSymlink:
+			err = x.extract(hdr.Name, info, hdr.Linkname, tr)
+		case tar.TypeLink:
+			err = x.link(hdr.Name, hdr.Linkname)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if errfinish := x.finish(); err == nil {
+		err = errfinish
+	}
+	return
+}
+
+func extractZip(zr *zip.Reader, dstDirPath string, opts *ArchiveOptions) (err error) {
+	var x *archiveExtractor
+	if x, err = newArchiveExtractor(dstDirPath, opts); err != nil {
+		return
+	}
+	for _, zf := range zr.File {
+		var src io.ReadCloser
+		if src, err = zf.Open(); err == nil {
+			info, link := zf.FileInfo(), ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				var data []byte
+				if data, err = ioutil.ReadAll(io.LimitReader(src, 4096)); err == nil {
+					link = string(data)
+				}
+			}
+			if err == nil {
+				err = x.extract(zf.Name, info, link, src)
+			}
+			src.Close()
+		}
+		if err != nil {
+			break
+		}
+	}
+	if errfinish := x.finish(); err == nil {
+		err = errfinish
+	}
+	return
+}
+
+//	The state of extracting one archive.
+type archiveExtractor struct {
+	dstDirPath string
+	realDir    string // `dstDirPath` with all symbolic links resolved
+	opts       *ArchiveOptions
+	matcher    ustr.Matcher
+	dirs       []archiveDir
+}
+
+func newArchiveExtractor(dstDirPath string, opts *ArchiveOptions) (me *archiveExtractor, err error) {
+	me = &archiveExtractor{dstDirPath: dstDirPath, opts: opts}
+	me.matcher.AddPatterns(opts.Patterns...)
+	if err = os.MkdirAll(dstDirPath, 0755); err == nil {
+		if me.realDir, err = filepath.EvalSymlinks(dstDirPath); err == nil {
+			me.realDir, err = filepath.Abs(me.realDir)
+		}
+	}
+	return
+}
+
+//	Returns whether `realPath` (with all symbolic links resolved) is `me.realDir` or inside it.
+func (me *archiveExtractor) isInside(realPath string) bool {
+	rel, err := filepath.Rel(me.realDir, realPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+//	A directory whose permissions and modification time get set by `archiveExtractor.finish`.
+type archiveDir struct {
+	path    string
+	mode    os.FileMode
+	modTime time.Time
+}
+
+//	Returns the path in `me.dstDirPath` for the archive entry `name`, `""` if it's to be skipped
+//	(as per `StripComponents` and `Patterns`), or an `error` if it would end up outside of `me.dstDirPath`.
+func (me *archiveExtractor) target(name string) (string, error) {
+	name = strings.Replace(name, "\\", "/", -1)
+	if path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", umisc.E("archive entry with absolute path: " + name)
+	}
+	if name = path.Clean(name); name == ".." || strings.HasPrefix(name, "../") {
+		return "", umisc.E("archive entry outside of target directory: " + name)
+	}
+	parts := strings.Split(name, "/")
+	if name == "." || len(parts) <= me.opts.StripComponents {
+		return "", nil
+	}
+	if name = strings.Join(parts[me.opts.StripComponents:], "/"); len(me.opts.Patterns) > 0 && !me.matcher.IsMatch(name) {
+		return "", nil
+	}
+	return filepath.Join(me.dstDirPath, filepath.FromSlash(name)), nil
+}
+
+func (me *archiveExtractor) extract(name string, info os.FileInfo, link string, src io.Reader) (err error) {
+	var dstpath string
+	if dstpath, err = me.target(name); err != nil || dstpath == "" {
+		return
+	}
+	mode := info.Mode()
+	if me.opts.IgnorePerms {
+		mode = (mode &^ os.ModePerm) | archivePerm(mode, mode.IsDir())
+	}
+	var realdir string
+	if realdir, err = me.prepare(dstpath, mode.IsDir()); err != nil {
+		return
+	} else if mode.IsDir() {
+		if err = os.MkdirAll(dstpath, 0755); err == nil {
+			me.dirs = append(me.dirs, archiveDir{path: dstpath, mode: mode.Perm(), modTime: info.ModTime()})
+		}
+		return
+	}
+	if mode&os.ModeSymlink != 0 {
+		// the link target must stay within `me.realDir`, too. Its leading `..`s are resolved against the link's real
+		// directory, the rest then only descends (through links that all passed this check, thus also staying inside).
+		if link = filepath.FromSlash(strings.Replace(link, "\\", "/", -1)); filepath.IsAbs(link) || filepath.VolumeName(link) != "" {
+			return umisc.E("archive entry " + name + " links to absolute path " + link)
+		}
+		dir, descending := realdir, false
+		for _, part := range strings.Split(link, string(filepath.Separator)) {
+			if part == ".." && descending {
+				return umisc.E("archive entry " + name + " links via `..` after a path component: " + link)
+			} else if part == ".." {
+				dir = filepath.Dir(dir)
+			} else if part != "." && part != "" {
+				descending = true
+			}
+		}
+		if !me.isInside(dir) {
+			return umisc.E("archive entry " + name + " links outside of target directory: " + link)
+		}
+		return os.Symlink(link, dstpath)
+	}
+	if !mode.IsRegular() {
+		return
+	}
+	var file *os.File
+	if file, err = os.OpenFile(dstpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()); err == nil {
+		_, err = io.Copy(file, src)
+		if errclose := file.Close(); err == nil {
+			err = errclose
+		}
+	}
+	if err == nil {
+		err = me.setAttrs(dstpath, mode.Perm(), info.ModTime())
+	}
+	return
+}
+
+//	Creates the hard link `name` to the earlier-extracted `linkName` (both archive entry paths).
+func (me *archiveExtractor) link(name string, linkName string) (err error) {
+	var dstpath, linkpath string
+	if dstpath, err = me.target(name); err != nil || dstpath == "" {
+		return
+	} else if linkpath, err = me.target(linkName); err != nil {
+		return
+	} else if linkpath == "" {
+		return umisc.E("archive entry " + name + " links to skipped entry " + linkName)
+	}
+	if _, err = me.prepare(dstpath, false); err == nil {
+		err = os.Link(linkpath, dstpath)
+	}
+	return
+}
+
+//	Ensures `dstPath`'s directory exists (and, with all symbolic links resolved, is `realDir` inside of
+//	`me.realDir`), and removes whatever is at `dstPath` (except a directory, if `isDir`), so that it never gets written through.
+func (me *archiveExtractor) prepare(dstPath string, isDir bool) (realDir string, err error) {
+	if err = os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return
+	} else if realDir, err = filepath.EvalSymlinks(filepath.Dir(dstPath)); err != nil {
+		return
+	} else if realDir, err = filepath.Abs(realDir); err != nil {
+		return
+	} else if !me.isInside(realDir) {
+		return "", umisc.E("archive entry outside of target directory: " + dstPath)
+	}
+	if stat, errstat := os.Lstat(dstPath); errstat == nil && !(isDir && stat.IsDir()) {
+		// even a regular file is removed rather than truncated: it might be hard-linked elsewhere
+		err = os.RemoveAll(dstPath)
+	}
+	return
+}
+
+func (me *archiveExtractor) setAttrs(dstPath string, perm os.FileMode, modTime time.Time) (err error) {
+	if err = os.Chmod(dstPath, perm); err == nil && !(me.opts.IgnoreTimes || modTime.IsZero()) {
+		err = os.Chtimes(dstPath, modTime, modTime)
+	}
+	return
+}
+
+//	Sets the directories' permissions and modification times, deepest first: only now that
+//	all their contents are extracted, since that modified them (and might have needed write access).
+func (me *archiveExtractor) finish() (err error) {
+	sort.SliceStable(me.dirs, func(i int, j int) bool { return len(me.dirs[i].path) > len(me.dirs[j].path) })
+	for _, dir := range me.dirs {
+		if stat, errstat := os.Lstat(dir.path); errstat != nil || !stat.IsDir() {
+			continue // replaced by a later entry: whatever that is, it's not to be written through
+		}
+		if errattrs := me.setAttrs(dir.path, dir.mode, dir.modTime); err == nil {
+			err = errattrs
+		}
+	}
+	return
+}
+
+//	The permissions used for `ArchiveOptions.IgnorePerms`.
+func archivePerm(mode os.FileMode, isDir bool) os.FileMode {
+	if isDir || mode&0100 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
+//	An `os.FileInfo` with a different `Mode`, for `ArchiveOptions.IgnorePerms`.
+type archiveFileInfo struct {
+	os.FileInfo
+	mode os.FileMode
+}
+
+func (me *archiveFileInfo) Mode() os.FileMode {
+	return me.mode
+}
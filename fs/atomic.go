@@ -0,0 +1,165 @@
+package ufs
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+//	Options for `SaveToFileAtomic` and the other `*Atomic` writing functions.
+type AtomicWriteOptions struct {
+	//	The permission bits for newly created files. Defaults to `0644` if `0`.
+	//	(Not `ModePerm`, since the temporary file gets `chmod`ed and so no umask applies.)
+	Mode os.FileMode
+
+	//	If `true` and the target file already exists, its permission bits are kept (instead of `Mode`).
+	PreservePerm bool
+
+	//	If `true` and the target file already exists, its previous version is kept as `BackupFilePath(target)`.
+	KeepBackup bool
+
+	//	If `true`, an exclusive `LockFile` on the target file is held for the duration of the write.
+	Lock bool
+
+	//	If `true`, no `fsync`s are performed: the write stays atomic to concurrent readers but is not crash-safe.
+	NoSync bool
+}
+
+var (
+	//	Used by all `*Atomic` writing functions when called with `nil` options.
+	AtomicWriteDefaults = AtomicWriteOptions{PreservePerm: true}
+
+	//	Appended to a file path to obtain its `BackupFilePath`.
+	BackupFileSuffix = ".bak"
+
+	//	Appended to a file path to obtain its `LockFilePath`.
+	LockFileSuffix = ".lock"
+
+	//	On platforms without `flock`, the interval at which `LockFile` re-checks a held lock.
+	LockFilePollInterval = 50 * time.Millisecond
+
+	//	On platforms without `flock`, a held lock's side-car file is touched at a quarter of this interval, and
+	//	`LockFile` removes one that wasn't for this long, as left behind by a crashed process. `0` to never do so.
+	LockFileStaleAfter = time.Minute
+)
+
+//	Returns the path of the backup file kept for `filePath` when writing with `AtomicWriteOptions.KeepBackup`.
+func BackupFilePath(filePath string) string {
+	return filePath + BackupFileSuffix
+}
+
+//	Returns the path of the side-car file used by `LockFile` for `filePath`.
+func LockFilePath(filePath string) string {
+	return filePath + LockFileSuffix
+}
+
+//	Like `SaveToFile`, but crash-safe: `src` is first written to a temporary file in the same
+//	directory as `dstFilePath`, which is then `fsync`ed and renamed to `dstFilePath`, after which
+//	the directory gets `fsync`ed too. Thus `dstFilePath` at all times contains either its complete
+//	previous or its complete new contents. Also ensures the target file's directory exists.
+func SaveToFileAtomic(src io.Reader, dstFilePath string, opts *AtomicWriteOptions) (err error) {
+	if opts == nil {
+		opts = &AtomicWriteDefaults
+	}
+	dirpath, mode := filepath.Dir(dstFilePath), opts.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	if err = EnsureDirExists(dirpath); err != nil {
+		return
+	}
+	if opts.Lock {
+		var unlock func() error
+		if unlock, err = LockFile(dstFilePath); err != nil {
+			return
+		}
+		defer func() {
+			if errunlock := unlock(); err == nil {
+				err = errunlock
+			}
+		}()
+	}
+	stat, errstat := os.Stat(dstFilePath)
+	if opts.PreservePerm && errstat == nil {
+		mode = stat.Mode().Perm()
+	}
+
+	var tmpfile *os.File
+	if tmpfile, err = ioutil.TempFile(dirpath, "."+filepath.Base(dstFilePath)+".tmp"); err != nil {
+		return
+	}
+	tmpfilepath := tmpfile.Name()
+	defer func() {
+		if err != nil {
+			os.Remove(tmpfilepath)
+		}
+	}()
+	if _, err = io.Copy(tmpfile, src); err == nil {
+		if err = tmpfile.Chmod(mode); err == nil && !opts.NoSync {
+			err = tmpfile.Sync()
+		}
+	}
+	if errclose := tmpfile.Close(); err != nil {
+		return
+	} else if err = errclose; err != nil {
+		return
+	}
+
+	if opts.KeepBackup && errstat == nil {
+		if err = keepBackup(dstFilePath); err != nil {
+			return
+		}
+	}
+	if err = os.Rename(tmpfilepath, dstFilePath); err == nil && !opts.NoSync {
+		err = syncDir(dirpath)
+	}
+	return
+}
+
+//	Like `WriteBinaryFile`, but via `SaveToFileAtomic`.
+func WriteBinaryFileAtomic(filePath string, contents []byte, opts *AtomicWriteOptions) error {
+	return SaveToFileAtomic(bytes.NewReader(contents), filePath, opts)
+}
+
+//	Like `WriteTextFile`, but via `SaveToFileAtomic`.
+func WriteTextFileAtomic(filePath, contents string, opts *AtomicWriteOptions) error {
+	return WriteBinaryFileAtomic(filePath, []byte(contents), opts)
+}
+
+//	Like `umisc.JsonEncodeToFile`, but via `SaveToFileAtomic`.
+func JsonEncodeToFileAtomic(from interface{}, toFilePath string, opts *AtomicWriteOptions) (err error) {
+	var buf bytes.Buffer
+	if err = json.NewEncoder(&buf).Encode(from); err == nil {
+		err = SaveToFileAtomic(&buf, toFilePath, opts)
+	}
+	return
+}
+
+func keepBackup(filePath string) (err error) {
+	bakfilepath := BackupFilePath(filePath)
+	if err = os.Remove(bakfilepath); err == nil || os.IsNotExist(err) {
+		if err = os.Link(filePath, bakfilepath); err != nil {
+			err = CopyFile(filePath, bakfilepath)
+		}
+	}
+	return
+}
+
+func syncDir(dirPath string) (err error) {
+	if runtime.GOOS == "windows" {
+		return // directories can't be opened for fsync-ing there, and renames are journaled anyway
+	}
+	var dir *os.File
+	if dir, err = os.Open(dirPath); err == nil {
+		err = dir.Sync()
+		if errclose := dir.Close(); err == nil {
+			err = errclose
+		}
+	}
+	return
+}
@@ -0,0 +1,287 @@
+package ufs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//	The recorded state of a file in a `FileStates` database.
+type FileState struct {
+	Size    int64
+	ModTime int64  // in `UnixNano`
+	Inode   uint64 // `0` on platforms without inodes
+	Hash    string // hex-encoded SHA-256 of the contents
+}
+
+//	A persistent database of `FileState`s (by absolute file path) that answers which input files
+//	changed since they were last `Record`ed, as a robust replacement for comparing modification
+//	times (see `IsNewerThan`): a file whose size, modification time or inode differs from its
+//	recorded state gets hashed, and counts as changed only if its contents differ. Thus a
+//	`git checkout` or a copy that merely touches files causes no spurious changes. To also catch
+//	changes that keep size, modification time and inode (coarse file-system clocks), set `AlwaysHash`.
+//
+//	Usage:
+//		db, err := ufs.OpenFileStates(dbFilePath) // such as `usys.FileStatesPath("myapp")`
+//		changed, err := db.Changed(inputs...)
+//		if len(changed) > 0 { rebuild() }
+//		err = db.Record(inputs...)
+//		err = db.Save()
+//
+//	Safe for concurrent use.
+type FileStates struct {
+	//	Where `Save` writes to. Set by `OpenFileStates`.
+	FilePath string
+
+	//	If `true`, files always get hashed, rather than only when their size, modification time or inode changed.
+	AlwaysHash bool
+
+	mutex sync.Mutex
+	files map[string]FileState
+	dirty bool
+}
+
+//	The format persisted by `FileStates.Save`.
+type fileStatesJson struct {
+	Version int
+	Files   map[string]FileState
+}
+
+//	Loads the `FileStates` database stored at `filePath` by a previous `Save`, or returns an empty one if there's none yet.
+func OpenFileStates(filePath string) (me *FileStates, err error) {
+	me = &FileStates{FilePath: filePath, files: map[string]FileState{}}
+	var file *os.File
+	if file, err = os.Open(filePath); os.IsNotExist(err) {
+		err = nil
+	} else if err == nil {
+		defer file.Close()
+		var stored fileStatesJson
+		if err = json.NewDecoder(file).Decode(&stored); err == nil && stored.Files != nil {
+			me.files = stored.Files
+		}
+	}
+	return
+}
+
+//	Writes `me` to `me.FilePath` via `JsonEncodeToFileAtomic`, if anything was `Record`ed or `Forget`ten since opening or the last `Save`.
+func (me *FileStates) Save() (err error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if me.dirty {
+		if err = JsonEncodeToFileAtomic(&fileStatesJson{Version: 1, Files: me.files}, me.FilePath, &AtomicWriteOptions{Lock: true}); err == nil {
+			me.dirty = false
+		}
+	}
+	return
+}
+
+//	Returns the recorded state of `filePath`, if any.
+func (me *FileStates) Get(filePath string) (state FileState, ok bool) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	state, ok = me.files[fileStatesKey(filePath)]
+	return
+}
+
+//	Returns those of `filePaths` that were created, modified or deleted since they were last `Record`ed.
+//	Files never recorded count as changed if they exist, files neither recorded nor existing don't.
+//	Does not record anything. `err` is the first failure to stat or read a file.
+func (me *FileStates) Changed(filePaths ...string) (changed []string, err error) {
+	for _, filePath := range filePaths {
+		var ischanged bool
+		if _, _, ischanged, err = me.check(filePath); err != nil {
+			return
+		} else if ischanged {
+			changed = append(changed, filePath)
+		}
+	}
+	return
+}
+
+//	Returns whether any of `filePaths` changed since they were last `Record`ed (see `Changed`).
+func (me *FileStates) AnyChanged(filePaths ...string) (bool, error) {
+	for _, filePath := range filePaths {
+		if _, _, ischanged, err := me.check(filePath); err != nil || ischanged {
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+//	Records the current states of `filePaths`, and forgets those that no longer exist. Call `Save` to persist.
+func (me *FileStates) Record(filePaths ...string) (err error) {
+	for _, filePath := range filePaths {
+		var state FileState
+		var exists bool
+		if state, exists, _, err = me.check(filePath); err != nil {
+			return
+		}
+		me.mutex.Lock()
+		if key := fileStatesKey(filePath); exists {
+			me.files[key] = state
+		} else {
+			delete(me.files, key)
+		}
+		me.dirty = true
+		me.mutex.Unlock()
+	}
+	return
+}
+
+//	Removes the recorded states of `filePaths` (and, for directories, of all files inside them). Call `Save` to persist.
+func (me *FileStates) Forget(filePaths ...string) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	for _, filePath := range filePaths {
+		key := fileStatesKey(filePath)
+		for _, recorded := range me.recordedIn(key) {
+			delete(me.files, recorded)
+		}
+		delete(me.files, key)
+	}
+	me.dirty = true
+}
+
+//	Walks `dirPath` with `walker` (its own file visitor, if any, still gets called and can stop the walk) and returns
+//	the files that changed since they were last recorded (see `Changed`), including those recorded inside `dirPath`
+//	that no longer exist (unless the walk stopped early, or they're ignored by `walker.Ignore` or inside sub-directories
+//	that `walker` doesn't visit). If `record`, also records all of them. `walker` may be `nil` for `NewDirWalker(true, nil, nil)`.
+func (me *FileStates) WalkChanged(walker *DirWalker, dirPath string, record bool) (changed []string, errs []error) {
+	if walker == nil {
+		walker = NewDirWalker(true, nil, nil)
+	}
+	var mutex sync.Mutex // for `walker.Workers`
+	w, visited, complete := *walker, map[string]bool{}, true
+	w.FileEntryVisitor = func(filePath string, entry os.DirEntry) (keepWalking bool) {
+		if walker.FileEntryVisitor != nil {
+			keepWalking = walker.FileEntryVisitor(filePath, entry)
+		} else {
+			keepWalking = walker.FileVisitor == nil || walker.FileVisitor(filePath)
+		}
+		if keepWalking {
+			ischanged, err := me.walkCheck(filePath, record)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if visited[fileStatesKey(filePath)] = true; err != nil {
+				errs = append(errs, err)
+				keepWalking = !w.BreakOnError
+			} else if ischanged {
+				changed = append(changed, filePath)
+			}
+		} else {
+			mutex.Lock()
+			defer mutex.Unlock()
+		}
+		complete = complete && keepWalking
+		return
+	}
+	if errs = append(errs, w.Walk(dirPath)...); !complete || len(errs) > 0 {
+		return
+	}
+
+	absDirPath, gone := fileStatesKey(dirPath), []string{}
+	me.mutex.Lock()
+	for _, key := range me.recordedIn(absDirPath) {
+		if !(visited[key] || (!w.VisitSubDirs && filepath.Dir(key) != absDirPath)) {
+			gone = append(gone, key)
+		}
+	}
+	me.mutex.Unlock()
+	for _, key := range gone {
+		if w.Ignore != nil && w.Ignore.IsIgnored(key, false) {
+			continue
+		} else if _, err := os.Lstat(key); os.IsNotExist(err) {
+			changed = append(changed, filepath.Join(dirPath, key[len(absDirPath):]))
+			if record {
+				me.mutex.Lock()
+				delete(me.files, key)
+				me.dirty = true
+				me.mutex.Unlock()
+			}
+		}
+	}
+	return
+}
+
+func (me *FileStates) walkCheck(filePath string, record bool) (ischanged bool, err error) {
+	var state FileState
+	var exists bool
+	if state, exists, ischanged, err = me.check(filePath); err == nil && record && exists {
+		me.mutex.Lock()
+		me.files[fileStatesKey(filePath)], me.dirty = state, true
+		me.mutex.Unlock()
+	}
+	return
+}
+
+//	Returns the current `state` of `filePath`, and whether it `exists` and has `changed` since it was last recorded.
+//	Only hashes `filePath` if it differs from its recorded state in size, modification time or inode (or if `AlwaysHash`).
+//	If its contents turn out unchanged, its recorded state gets refreshed (so that it doesn't need hashing again).
+func (me *FileStates) check(filePath string) (state FileState, exists bool, changed bool, err error) {
+	key := fileStatesKey(filePath)
+	me.mutex.Lock()
+	recorded, wasrecorded := me.files[key]
+	me.mutex.Unlock()
+
+	var fileinfo os.FileInfo
+	if fileinfo, err = os.Stat(filePath); os.IsNotExist(err) {
+		return state, false, wasrecorded, nil
+	} else if err != nil {
+		return
+	}
+	exists = true
+	state = FileState{Size: fileinfo.Size(), ModTime: fileinfo.ModTime().UnixNano(), Inode: fileInode(fileinfo)}
+	if wasrecorded && !me.AlwaysHash && state.Size == recorded.Size && state.ModTime == recorded.ModTime && state.Inode == recorded.Inode {
+		state.Hash = recorded.Hash
+		return
+	}
+	if state.Hash, err = fileStatesHash(OsFs, filePath); err != nil {
+		return
+	}
+	if changed = !(wasrecorded && state.Hash == recorded.Hash); !changed && state != recorded {
+		me.mutex.Lock()
+		me.files[key], me.dirty = state, true
+		me.mutex.Unlock()
+	}
+	return
+}
+
+//	Returns the sorted recorded keys inside `absDirPath`. Must be called with `me.mutex` locked.
+func (me *FileStates) recordedIn(absDirPath string) (keys []string) {
+	prefix := absDirPath
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	for key := range me.files {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return
+}
+
+func fileStatesKey(filePath string) string {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		return abs
+	}
+	return filepath.Clean(filePath)
+}
+
+func fileStatesHash(fsys Fs, filePath string) (hash string, err error) {
+	var file FsFile
+	if file, err = fsys.Open(filePath); err == nil {
+		defer file.Close()
+		h := sha256.New()
+		if _, err = io.Copy(h, file); err == nil {
+			hash = hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	return
+}
@@ -3,20 +3,24 @@ package ufs
 import (
 	"archive/zip"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 
+	"github.com/metaleap/go-util"
+	"github.com/metaleap/go-util/log"
 	"github.com/metaleap/go-util/slice"
 	"github.com/metaleap/go-util/str"
 )
 
 //	Handles a file-system notification originating in a `Watcher`.
-type WatcherHandler func(path string)
+type WatcherHandler func(evt WatchEvent)
 
 var (
+	//	The `ulog.Logger` that this package reports to.
+	Log = ulog.Default.Sub("ufs")
+
 	//	The permission bits used in the `EnsureDirExists`, `WriteBinaryFile` and `WriteTextFile` functions.
 	ModePerm = os.ModePerm
 )
@@ -38,126 +42,59 @@ func PathPrefix(val string, pathprefix string) bool {
 
 //	Removes anything in `dirPath` (but not `dirPath` itself), except items whose `os.FileInfo.Name` matches any of the specified `keepNamePatterns`.
 func ClearDirectory(dirPath string, keepNamePatterns ...string) (err error) {
-	var fileInfos []os.FileInfo
-	var matcher ustr.Matcher
-	matcher.AddPatterns(keepNamePatterns...)
-	if fileInfos, err = ioutil.ReadDir(dirPath); err == nil {
-		for _, fi := range fileInfos {
-			if fn := fi.Name(); !matcher.IsMatch(fn) {
-				if err = os.RemoveAll(filepath.Join(dirPath, fn)); err != nil {
-					return
-				}
-			}
-		}
-	}
-	return
+	return On(nil).ClearDirectory(dirPath, keepNamePatterns...)
+}
+
+//	Removes anything in `dirPath` (but not `dirPath` itself), except items ignored by `keep` (whose
+//	`RootDirPath` is `dirPath` or one of its ancestors). Sub-directories containing any such items are
+//	cleared the same way instead of being removed.
+func ClearDirectoryIgnoring(dirPath string, keep *Ignorer) (err error) {
+	return On(nil).ClearDirectoryIgnoring(dirPath, keep)
 }
 
 //	Removes all directories inside `dirPath`, except those that
 //	contain files or descendent directories that contain files.
 func ClearEmptyDirectories(dirPath string) (canDelete bool, err error) {
-	var (
-		fi     os.FileInfo
-		subs   []os.FileInfo
-		canDel bool
-		subDir string
-	)
-	canDelete = true
-	if subs, err = ioutil.ReadDir(dirPath); err == nil {
-		for _, fi = range subs {
-			if fi.IsDir() {
-				subDir = filepath.Join(dirPath, fi.Name())
-				if canDel, err = ClearEmptyDirectories(subDir); err != nil {
-					break
-				} else if !canDel {
-					canDelete = false
-				} else if err = os.RemoveAll(subDir); err != nil {
-					break
-				}
-			} else {
-				canDelete = false
-			}
-		}
-	}
-	if err != nil {
-		canDelete = false
-	}
-	return
+	return On(nil).ClearEmptyDirectories(dirPath)
 }
 
 //	Copies all files and directories inside `srcDirPath` to `dstDirPath`.
 //	All sub-directories whose `os.FileInfo.Name` is matched by `skipDirs` (optional) are skipped.
 func CopyAll(srcDirPath, dstDirPath string, skipDirs *ustr.Matcher, skipFileSuffix string) (err error) {
-	var (
-		srcPath, destPath string
-		fileInfos         []os.FileInfo
-	)
-	if fileInfos, err = ioutil.ReadDir(srcDirPath); err == nil {
-		EnsureDirExists(dstDirPath)
-		for _, fi := range fileInfos {
-			if srcPath, destPath = filepath.Join(srcDirPath, fi.Name()), filepath.Join(dstDirPath, fi.Name()); fi.IsDir() {
-				if skipDirs == nil || !skipDirs.IsMatch(fi.Name()) {
-					if skipFileSuffix == "" || !strings.HasSuffix(srcPath, skipFileSuffix) {
-						CopyAll(srcPath, destPath, skipDirs, skipFileSuffix)
-					}
-				}
-			} else {
-				CopyFile(srcPath, destPath)
-			}
-		}
-	}
-	return
+	return On(nil).CopyAll(srcDirPath, dstDirPath, skipDirs, skipFileSuffix)
+}
+
+//	Copies all files and directories inside `srcDirPath` to `dstDirPath`, except those ignored by `skip`
+//	(whose `RootDirPath` is usually `srcDirPath`, so that the `.gitignore` files in there are honoured).
+func CopyAllIgnoring(srcDirPath, dstDirPath string, skip *Ignorer) (err error) {
+	return On(nil).CopyAllIgnoring(srcDirPath, dstDirPath, skip)
 }
 
 //	Performs an `io.Copy` from the specified source file to the specified destination file.
 func CopyFile(srcFilePath, dstFilePath string) (err error) {
-	var src *os.File
-	if src, err = os.Open(srcFilePath); err != nil {
-		return
-	}
-	defer src.Close()
-	err = SaveToFile(src, dstFilePath)
-	return
+	return On(nil).CopyFile(srcFilePath, dstFilePath)
 }
 
 //	Returns whether a directory (not a file) exists at the specified `dirpath`.
 func DirExists(dirpath string) bool {
-	if len(dirpath) == 0 {
-		return false
-	}
-	stat, err := os.Stat(dirpath)
-	return err == nil && stat.IsDir()
+	return On(nil).DirExists(dirpath)
 }
 
 //	Returns whether all of the specified `dirOrFileNames` exist in `dirPath`.
 func DirsOrFilesExistIn(dirPath string, dirOrFileNames ...string) bool {
-	var (
-		err  error
-		stat os.FileInfo
-	)
-	for _, name := range dirOrFileNames {
-		if stat, err = os.Stat(filepath.Join(dirPath, name)); err != nil || stat == nil {
-			return false
-		}
-
-	}
-	return true
+	return On(nil).DirsOrFilesExistIn(dirPath, dirOrFileNames...)
 }
 
 //	If a directory does not exist at the specified `dirPath`, attempts to create it.
 func EnsureDirExists(dirPath string) (err error) {
-	if !DirExists(dirPath) {
-		if err = EnsureDirExists(filepath.Dir(dirPath)); err == nil {
-			err = os.Mkdir(dirPath, ModePerm)
-		}
-	}
-	return
+	return On(nil).EnsureDirExists(dirPath)
 }
 
 //	Extracts a ZIP archive to the local file system.
 //	zipFilePath: full file path to the ZIP archive file.
 //	targetDirPath: directory path where un-zipped archive contents are extracted to.
 //	deleteZipFile: deletes the ZIP archive file upon successful extraction.
+//	Entries whose paths would end up outside of `targetDirPath` cause an `error`. For more options and formats, see `ExtractArchive`.
 func ExtractZipFile(zipFilePath, targetDirPath string, deleteZipFile bool, fileNamesPrefix string, fileNamesToExtract ...string) error {
 	var (
 		fnames      []string
@@ -179,9 +116,11 @@ func ExtractZipFile(zipFilePath, targetDirPath string, deleteZipFile bool, fileN
 			}
 			for _, zfile = range unzip.File {
 				if len(fnames) == 0 || uslice.StrHas(fnames, zfile.FileHeader.Name) {
-					if zfileReader, err = zfile.Open(); zfileReader != nil {
+					if rel := filepath.Clean(filepath.FromSlash(fnprefix + zfile.FileHeader.Name)); filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+						err = umisc.E("zip entry outside of target directory: " + zfile.FileHeader.Name)
+					} else if zfileReader, err = zfile.Open(); zfileReader != nil {
 						if err == nil {
-							if efile, err = os.Create(filepath.Join(targetDirPath, fnprefix+zfile.FileHeader.Name)); efile != nil {
+							if efile, err = os.Create(filepath.Join(targetDirPath, rel)); efile != nil {
 								if err == nil {
 									_, err = io.Copy(efile, zfileReader)
 								}
@@ -206,43 +145,22 @@ func ExtractZipFile(zipFilePath, targetDirPath string, deleteZipFile bool, fileN
 
 //	Returns whether a file (not a directory) exists at the specified `filePath`.
 func FileExists(filePath string) bool {
-	stat, err := os.Stat(filePath)
-	return err == nil && stat.Mode().IsRegular()
+	return On(nil).FileExists(filePath)
 }
 
-/*
 //	If a file with a given base-name and one of a set of extensions exists in the specified directory, returns details on it.
 //	The tryLower and tryUpper flags also test for upper-case and lower-case variants of the specified fileBaseName.
 func FindFileInfo(dirPath string, fileBaseName string, fileExts []string, tryLower bool, tryUpper bool) (fullFilePath string, fileInfo *os.FileInfo) {
-	var (
-		stat        os.FileInfo
-		err         error
-		fext, fpath string
-	)
-	for _, fext = range fileExts {
-		fpath = filepath.Join(dirPath, fileBaseName+fext)
-		if stat, err = os.Stat(fpath); err != nil {
-			if tryUpper {
-				fpath = filepath.Join(dirPath, strings.ToUpper(fileBaseName)+fext)
-				stat, err = os.Stat(fpath)
-			}
-			if (err != nil) && tryLower {
-				fpath = filepath.Join(dirPath, strings.ToLower(fileBaseName)+fext)
-				stat, err = os.Stat(fpath)
-			}
-		}
-		if (err == nil) && !stat.IsDir() {
-			return fpath, &stat
-		}
-	}
-	return "", nil
+	return On(nil).FindFileInfo(dirPath, fileBaseName, fileExts, tryLower, tryUpper)
 }
-*/
 
 //	Returns whether `srcFilePath` has been modified later than `dstFilePath`.
 //
 //	NOTE: be aware that `newer` will be returned as `true` if `err` is returned as *not* `nil`,
 //	since that is often more convenient for many use-cases.
+//
+//	Deprecated: modification times give spurious rebuilds after a `git checkout` or copy, and missed ones
+//	with coarse file-system clocks. Use a `FileStates` database and its `Changed` method instead.
 func IsNewerThan(srcFilePath, dstFilePath string) (newer bool, err error) {
 	var out, src os.FileInfo
 	newer = true
@@ -254,6 +172,9 @@ func IsNewerThan(srcFilePath, dstFilePath string) (newer bool, err error) {
 	return
 }
 
+//	Returns whether `srcFilePath` has been modified later than `time` (in `UnixNano`), or `true` if `time` is `0` or `err`.
+//
+//	Deprecated: see `IsNewerThan`, use `FileStates.Changed` instead.
 func IsNewerThanTime(srcFilePath string, time int64) (newer bool, err error) {
 	var src os.FileInfo
 	if newer = true; time > 0 {
@@ -265,18 +186,12 @@ func IsNewerThanTime(srcFilePath string, time int64) (newer bool, err error) {
 }
 
 func AllFilePathsIn(dirpath string, ignoresubpath string) (filepaths []string) {
-	if len(ignoresubpath) > 0 && !strings.HasPrefix(ignoresubpath, dirpath) {
-		ignoresubpath = filepath.Join(dirpath, ignoresubpath)
-	}
-	WalkAllFiles(dirpath, func(disfilepath string) (keepWalking bool) {
-		if !strings.HasPrefix(disfilepath, ignoresubpath) {
-			filepaths = append(filepaths, disfilepath)
-		}
-		return true
-	})
-	return
+	return On(nil).AllFilePathsIn(dirpath, ignoresubpath)
 }
 
+//	Returns whether any file in `dirpath` (other than `filepaths`) has been modified later than the oldest of `filepaths`.
+//
+//	Deprecated: see `IsNewerThan`, use `FileStates.WalkChanged` instead.
 func IsAnyInNewerThanAnyOf(dirpath string, filepaths ...string) (isAnyNewer bool) {
 	var cmpfiletimeoldest int64 = 0
 	if len(filepaths) == 0 {
@@ -324,11 +239,7 @@ func MatchesAny(name string, patterns ...string) (matchingPattern string, err er
 
 //	Reads and returns the binary contents of a file with non-idiomatic error handling, mostly for one-off `package main`s.
 func ReadBinaryFile(filePath string, panicOnError bool) []byte {
-	bytes, err := ioutil.ReadFile(filePath)
-	if panicOnError && (err != nil) {
-		panic(err)
-	}
-	return bytes
+	return On(nil).ReadBinaryFile(filePath, panicOnError)
 }
 
 /*
@@ -348,22 +259,11 @@ func ReadFromBinary(readSeeker io.ReadSeeker, offset int64, byteOrder binary.Byt
 
 //	Reads and returns the contents of a text file with non-idiomatic error handling, mostly for one-off `package main`s.
 func ReadTextFile(filePath string, panicOnError bool, defaultValue string) string {
-	bytes, err := ioutil.ReadFile(filePath)
-	if err == nil {
-		return string(bytes)
-	}
-	if panicOnError && (err != nil) {
-		panic(err)
-	}
-	return defaultValue
+	return On(nil).ReadTextFile(filePath, panicOnError, defaultValue)
 }
 
 func ReadFileIntoStr(filePath string, contents *string) error {
-	bytes, err := ioutil.ReadFile(filePath)
-	if err == nil {
-		*contents = string(bytes)
-	}
-	return err
+	return On(nil).ReadFileIntoStr(filePath, contents)
 }
 
 func SanitizeFsName(name string) string {
@@ -382,63 +282,63 @@ func SanitizeFsName(name string) string {
 
 //	Performs an `io.Copy` from the specified `io.Reader` to the specified local file.
 func SaveToFile(src io.Reader, dstFilePath string) (err error) {
-	var file *os.File
-	if file, err = os.Create(dstFilePath); file != nil {
-		defer file.Close()
-		if err == nil {
-			_, err = io.Copy(file, src)
-		}
-	}
-	return
+	return On(nil).SaveToFile(src, dstFilePath)
 }
 
 //	Calls `visitor` for `dirPath` and all descendent directories (but not files).
 func WalkAllDirs(dirPath string, visitor WalkerVisitor) []error {
-	return NewDirWalker(true, visitor, nil).Walk(dirPath)
+	return On(nil).WalkAllDirs(dirPath, visitor)
 }
 
 //	Calls `visitor` for all files (but not directories) directly or indirectly descendent to `dirPath`.
 func WalkAllFiles(dirPath string, visitor WalkerVisitor) []error {
-	return NewDirWalker(true, nil, visitor).Walk(dirPath)
+	return On(nil).WalkAllFiles(dirPath, visitor)
 }
 
 //	Calls `visitor` for all directories (but not files) in `dirPath`, but not their sub-directories and not `dirPath` itself.
 func WalkDirsIn(dirPath string, visitor WalkerVisitor) []error {
-	w := NewDirWalker(false, visitor, nil)
-	w.VisitSelf = false
-	return w.Walk(dirPath)
+	return On(nil).WalkDirsIn(dirPath, visitor)
 }
 
 //	Calls `visitor` for all files (but not directories) directly inside `dirPath`, but not for any inside sub-directories.
 func WalkFilesIn(dirPath string, visitor WalkerVisitor) []error {
-	w := NewDirWalker(false, nil, visitor)
-	w.VisitSelf = false
-	return w.Walk(dirPath)
+	return On(nil).WalkFilesIn(dirPath, visitor)
 }
 
 //	A short-hand for `ioutil.WriteFile` using `ModePerm`.
 //	Also ensures the target file's directory exists.
+//	For crash-safe writing, see `WriteBinaryFileAtomic`.
 func WriteBinaryFile(filePath string, contents []byte) error {
-	EnsureDirExists(filepath.Dir(filePath))
-	return ioutil.WriteFile(filePath, contents, ModePerm)
+	return On(nil).WriteBinaryFile(filePath, contents)
 }
 
 //	A short-hand for `ioutil.WriteFile`, using `ModePerm`.
 //	Also ensures the target file's directory exists.
 func WriteTextFile(filePath, contents string) error {
-	return WriteBinaryFile(filePath, []byte(contents))
+	return On(nil).WriteTextFile(filePath, contents)
 }
 
-func watchRunHandler(dirPath string, namePattern ustr.Pattern, handler WatcherHandler) []error {
-	vis := func(fullPath string) (keepWalking bool) {
-		keepWalking = true
-		if namePattern.IsMatch(filepath.Base(fullPath)) {
-			handler(fullPath)
+func watchRunHandler(dirPath string, namePattern ustr.Pattern, handler WatcherHandler, ignore *Ignorer, recursive bool) []error {
+	vis := func(isDir bool) WalkerVisitor {
+		return func(fullPath string) (keepWalking bool) {
+			keepWalking = true
+			if namePattern.IsMatch(filepath.Base(fullPath)) {
+				handler(WatchEvent{Path: fullPath, IsDir: isDir})
+			}
+			return
 		}
-		return
 	}
-	w := NewDirWalker(false, vis, vis)
+	w := NewDirWalker(recursive, vis(true), vis(false))
 	w.VisitSelf = false
 	w.VisitDirsFirst = true
+	w.Ignore = ignore
 	return w.Walk(dirPath)
 }
+
+func watchRunBatchHandler(dirPath string, namePattern ustr.Pattern, handler WatcherBatchHandler, ignore *Ignorer, recursive bool) (errs []error) {
+	var evts []WatchEvent
+	if errs = watchRunHandler(dirPath, namePattern, func(evt WatchEvent) { evts = append(evts, evt) }, ignore, recursive); len(evts) > 0 {
+		handler(evts)
+	}
+	return
+}
@@ -0,0 +1,299 @@
+package ufs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/metaleap/go-util/str"
+)
+
+//	Provides this package's file functions (such as `CopyAll` or `ClearDirectory`) on a specific `Fs`:
+//	`ufs.On(memFs).CopyAll(...)` is the in-memory equivalent of `ufs.CopyAll(...)`, which is short for `ufs.On(nil).CopyAll(...)`.
+type FsOps struct {
+	//	The file system operated on. If `nil`, `OsFs`.
+	Fs Fs
+}
+
+//	Returns the `FsOps` for `fsys` (or `OsFs` if `nil`).
+func On(fsys Fs) FsOps {
+	return FsOps{Fs: fsOrOs(fsys)}
+}
+
+func (me FsOps) fs() Fs {
+	return fsOrOs(me.Fs)
+}
+
+//	See the package-level `ClearDirectory`.
+func (me FsOps) ClearDirectory(dirPath string, keepNamePatterns ...string) (err error) {
+	var matcher ustr.Matcher
+	matcher.AddPatterns(keepNamePatterns...)
+	return me.clearDirectory(dirPath, &matcher, nil)
+}
+
+//	See the package-level `ClearDirectoryIgnoring`. `keep.Fs` should be `me.Fs`.
+func (me FsOps) ClearDirectoryIgnoring(dirPath string, keep *Ignorer) (err error) {
+	return me.clearDirectory(dirPath, nil, keep)
+}
+
+func (me FsOps) clearDirectory(dirPath string, keepNames *ustr.Matcher, keep *Ignorer) (err error) {
+	var entries, subEntries []os.DirEntry
+	if entries, err = me.fs().ReadDir(dirPath); err == nil {
+		for _, entry := range entries {
+			fn := entry.Name()
+			fullPath := filepath.Join(dirPath, fn)
+			if keepNames != nil && keepNames.IsMatch(fn) {
+				continue
+			} else if keep != nil {
+				if keep.IsIgnored(fullPath, entry.IsDir()) {
+					continue
+				} else if entry.IsDir() {
+					if err = me.clearDirectory(fullPath, nil, keep); err != nil {
+						return
+					} else if subEntries, err = me.fs().ReadDir(fullPath); err != nil {
+						return
+					} else if len(subEntries) > 0 {
+						continue
+					}
+				}
+			}
+			if err = me.fs().RemoveAll(fullPath); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+//	See the package-level `ClearEmptyDirectories`.
+func (me FsOps) ClearEmptyDirectories(dirPath string) (canDelete bool, err error) {
+	var (
+		subs   []os.DirEntry
+		canDel bool
+		subDir string
+	)
+	canDelete = true
+	if subs, err = me.fs().ReadDir(dirPath); err == nil {
+		for _, entry := range subs {
+			if entry.IsDir() {
+				subDir = filepath.Join(dirPath, entry.Name())
+				if canDel, err = me.ClearEmptyDirectories(subDir); err != nil {
+					break
+				} else if !canDel {
+					canDelete = false
+				} else if err = me.fs().RemoveAll(subDir); err != nil {
+					break
+				}
+			} else {
+				canDelete = false
+			}
+		}
+	}
+	if err != nil {
+		canDelete = false
+	}
+	return
+}
+
+//	See the package-level `CopyAll`.
+func (me FsOps) CopyAll(srcDirPath, dstDirPath string, skipDirs *ustr.Matcher, skipFileSuffix string) (err error) {
+	return me.copyAll(srcDirPath, dstDirPath, skipDirs, skipFileSuffix, nil)
+}
+
+//	See the package-level `CopyAllIgnoring`. `skip.Fs` should be `me.Fs`.
+func (me FsOps) CopyAllIgnoring(srcDirPath, dstDirPath string, skip *Ignorer) (err error) {
+	return me.copyAll(srcDirPath, dstDirPath, nil, "", skip)
+}
+
+func (me FsOps) copyAll(srcDirPath, dstDirPath string, skipDirs *ustr.Matcher, skipFileSuffix string, skip *Ignorer) (err error) {
+	var (
+		srcPath, destPath string
+		entries           []os.DirEntry
+	)
+	if entries, err = me.fs().ReadDir(srcDirPath); err == nil {
+		me.EnsureDirExists(dstDirPath)
+		for _, entry := range entries {
+			if srcPath, destPath = filepath.Join(srcDirPath, entry.Name()), filepath.Join(dstDirPath, entry.Name()); skip != nil && skip.IsIgnored(srcPath, entry.IsDir()) {
+				continue
+			} else if entry.IsDir() {
+				if skipDirs == nil || !skipDirs.IsMatch(entry.Name()) {
+					if skipFileSuffix == "" || !strings.HasSuffix(srcPath, skipFileSuffix) {
+						me.copyAll(srcPath, destPath, skipDirs, skipFileSuffix, skip)
+					}
+				}
+			} else {
+				me.CopyFile(srcPath, destPath)
+			}
+		}
+	}
+	return
+}
+
+//	See the package-level `CopyFile`.
+func (me FsOps) CopyFile(srcFilePath, dstFilePath string) (err error) {
+	var src FsFile
+	if src, err = me.fs().Open(srcFilePath); err != nil {
+		return
+	}
+	defer src.Close()
+	err = me.SaveToFile(src, dstFilePath)
+	return
+}
+
+//	See the package-level `DirExists`.
+func (me FsOps) DirExists(dirpath string) bool {
+	if len(dirpath) == 0 {
+		return false
+	}
+	stat, err := me.fs().Stat(dirpath)
+	return err == nil && stat.IsDir()
+}
+
+//	See the package-level `DirsOrFilesExistIn`.
+func (me FsOps) DirsOrFilesExistIn(dirPath string, dirOrFileNames ...string) bool {
+	for _, name := range dirOrFileNames {
+		if stat, err := me.fs().Stat(filepath.Join(dirPath, name)); err != nil || stat == nil {
+			return false
+		}
+	}
+	return true
+}
+
+//	See the package-level `EnsureDirExists`.
+func (me FsOps) EnsureDirExists(dirPath string) (err error) {
+	if !me.DirExists(dirPath) {
+		if err = me.EnsureDirExists(filepath.Dir(dirPath)); err == nil {
+			err = me.fs().Mkdir(dirPath, ModePerm)
+		}
+	}
+	return
+}
+
+//	See the package-level `FileExists`.
+func (me FsOps) FileExists(filePath string) bool {
+	stat, err := me.fs().Stat(filePath)
+	return err == nil && stat.Mode().IsRegular()
+}
+
+//	See the package-level `FindFileInfo`.
+func (me FsOps) FindFileInfo(dirPath string, fileBaseName string, fileExts []string, tryLower bool, tryUpper bool) (fullFilePath string, fileInfo *os.FileInfo) {
+	var (
+		stat        os.FileInfo
+		err         error
+		fext, fpath string
+	)
+	for _, fext = range fileExts {
+		fpath = filepath.Join(dirPath, fileBaseName+fext)
+		if stat, err = me.fs().Stat(fpath); err != nil {
+			if tryUpper {
+				fpath = filepath.Join(dirPath, strings.ToUpper(fileBaseName)+fext)
+				stat, err = me.fs().Stat(fpath)
+			}
+			if (err != nil) && tryLower {
+				fpath = filepath.Join(dirPath, strings.ToLower(fileBaseName)+fext)
+				stat, err = me.fs().Stat(fpath)
+			}
+		}
+		if (err == nil) && !stat.IsDir() {
+			return fpath, &stat
+		}
+	}
+	return "", nil
+}
+
+//	See the package-level `AllFilePathsIn`.
+func (me FsOps) AllFilePathsIn(dirpath string, ignoresubpath string) (filepaths []string) {
+	if len(ignoresubpath) > 0 && !strings.HasPrefix(ignoresubpath, dirpath) {
+		ignoresubpath = filepath.Join(dirpath, ignoresubpath)
+	}
+	me.WalkAllFiles(dirpath, func(disfilepath string) (keepWalking bool) {
+		if !strings.HasPrefix(disfilepath, ignoresubpath) {
+			filepaths = append(filepaths, disfilepath)
+		}
+		return true
+	})
+	return
+}
+
+//	See the package-level `ReadBinaryFile`.
+func (me FsOps) ReadBinaryFile(filePath string, panicOnError bool) []byte {
+	bytes, err := fsReadFile(me.fs(), filePath)
+	if panicOnError && (err != nil) {
+		panic(err)
+	}
+	return bytes
+}
+
+//	See the package-level `ReadTextFile`.
+func (me FsOps) ReadTextFile(filePath string, panicOnError bool, defaultValue string) string {
+	bytes, err := fsReadFile(me.fs(), filePath)
+	if err == nil {
+		return string(bytes)
+	}
+	if panicOnError && (err != nil) {
+		panic(err)
+	}
+	return defaultValue
+}
+
+//	See the package-level `ReadFileIntoStr`.
+func (me FsOps) ReadFileIntoStr(filePath string, contents *string) error {
+	bytes, err := fsReadFile(me.fs(), filePath)
+	if err == nil {
+		*contents = string(bytes)
+	}
+	return err
+}
+
+//	See the package-level `SaveToFile`.
+func (me FsOps) SaveToFile(src io.Reader, dstFilePath string) (err error) {
+	var file FsFile
+	if file, err = me.fs().OpenFile(dstFilePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666); err == nil {
+		defer file.Close()
+		_, err = io.Copy(file, src)
+	}
+	return
+}
+
+//	Returns a `NewDirWalker` that walks `me.Fs`.
+func (me FsOps) NewDirWalker(deep bool, dirVisitor, fileVisitor WalkerVisitor) (walker *DirWalker) {
+	walker = NewDirWalker(deep, dirVisitor, fileVisitor)
+	walker.Fs = me.Fs
+	return
+}
+
+//	See the package-level `WalkAllDirs`.
+func (me FsOps) WalkAllDirs(dirPath string, visitor WalkerVisitor) []error {
+	return me.NewDirWalker(true, visitor, nil).Walk(dirPath)
+}
+
+//	See the package-level `WalkAllFiles`.
+func (me FsOps) WalkAllFiles(dirPath string, visitor WalkerVisitor) []error {
+	return me.NewDirWalker(true, nil, visitor).Walk(dirPath)
+}
+
+//	See the package-level `WalkDirsIn`.
+func (me FsOps) WalkDirsIn(dirPath string, visitor WalkerVisitor) []error {
+	w := me.NewDirWalker(false, visitor, nil)
+	w.VisitSelf = false
+	return w.Walk(dirPath)
+}
+
+//	See the package-level `WalkFilesIn`.
+func (me FsOps) WalkFilesIn(dirPath string, visitor WalkerVisitor) []error {
+	w := me.NewDirWalker(false, nil, visitor)
+	w.VisitSelf = false
+	return w.Walk(dirPath)
+}
+
+//	See the package-level `WriteBinaryFile`.
+func (me FsOps) WriteBinaryFile(filePath string, contents []byte) error {
+	me.EnsureDirExists(filepath.Dir(filePath))
+	return fsWriteFile(me.fs(), filePath, contents, ModePerm)
+}
+
+//	See the package-level `WriteTextFile`.
+func (me FsOps) WriteTextFile(filePath, contents string) error {
+	return me.WriteBinaryFile(filePath, []byte(contents))
+}
@@ -0,0 +1,242 @@
+package ufs
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/metaleap/go-util/str"
+)
+
+//	The per-directory ignore-file names consulted by an `Ignorer` created via `NewIgnorer` without explicit `fileNames`.
+var IgnoreFileNames = []string{".gitignore", ".ignore"}
+
+type ignoreRule struct {
+	negated, dirOnly bool
+	glob             *regexp.Regexp
+}
+
+func (me *ignoreRule) isMatch(relPath string, isDir bool) bool {
+	return (isDir || !me.dirOnly) && me.glob.MatchString(relPath)
+}
+
+//	Decides which files and directories to skip, the way `git` does with `.gitignore` files:
+//	every directory (from `RootDirPath` downwards) may have its own ignore files, whose patterns
+//	apply to that directory's contents --- the deeper the ignore file, the higher its precedence,
+//	and within one ignore file, the last matching pattern decides. Supported are:
+//
+//	- `#` comment lines and blank lines (both skipped),
+//	- `!` negations, re-including what an earlier pattern excluded (but nothing inside an excluded directory),
+//	- a trailing `/` to only match directories,
+//	- "anchored" patterns containing a `/` (other than a trailing one), which match paths relative to
+//	the ignore file's directory, while all others match names at any depth below it,
+//	- `*`, `?`, `[a-z]`, `[!a-z]` and `**` wildcards as per `ustr.GlobRegexp` (but no `{a,b}` alternatives),
+//	- `\` escapes, such as for leading `#` or `!` or trailing spaces.
+//
+//	An `Ignorer` can be set as `DirWalker.Ignore` or `Watcher.Ignore`, or be passed to
+//	`CopyAllIgnoring` and `ClearDirectoryIgnoring`. It is safe for concurrent use.
+type Ignorer struct {
+	//	Paths outside this directory are never ignored, and ignore files above it are not consulted.
+	RootDirPath string
+
+	//	The ignore-file names to read in every directory.
+	FileNames []string
+
+	//	The file system to read ignore files from. If `nil`, `OsFs`.
+	Fs Fs
+
+	mutex  sync.Mutex
+	rules  map[string][]ignoreRule
+	extras map[string][]ignoreRule
+}
+
+//	Returns a new `Ignorer` for the specified `rootDirPath`, reading ignore files
+//	named `fileNames` (or if none are specified, `IgnoreFileNames`).
+func NewIgnorer(rootDirPath string, fileNames ...string) *Ignorer {
+	if len(fileNames) == 0 {
+		fileNames = IgnoreFileNames
+	}
+	if abspath, err := filepath.Abs(rootDirPath); err == nil {
+		rootDirPath = abspath
+	}
+	return &Ignorer{RootDirPath: filepath.Clean(rootDirPath), FileNames: fileNames}
+}
+
+//	Adds the specified `patterns` (in ignore-file syntax, see `Ignorer`) as if they
+//	appeared (last) in an ignore file in `dirPath`, which is often `me.RootDirPath`.
+//	They are kept across `Reload`s.
+func (me *Ignorer) AddPatterns(dirPath string, patterns ...string) {
+	dirPath = me.abs(dirPath)
+	rules := ignoreRules(patterns)
+	me.mutex.Lock()
+	if me.extras == nil {
+		me.extras = map[string][]ignoreRule{}
+	}
+	me.extras[dirPath] = append(me.extras[dirPath], rules...)
+	delete(me.rules, dirPath)
+	me.mutex.Unlock()
+}
+
+//	Forgets the already-read ignore files in `dirPath`, so that they will be read again as needed.
+//	If `dirPath` is empty, forgets all of them.
+func (me *Ignorer) Reload(dirPath string) {
+	me.mutex.Lock()
+	if len(dirPath) == 0 {
+		me.rules = nil
+	} else {
+		delete(me.rules, me.abs(dirPath))
+	}
+	me.mutex.Unlock()
+}
+
+//	Returns whether `path` (a directory if `isDir`, else a file) is ignored, either itself or by
+//	way of one of its ancestor directories being ignored.
+func (me *Ignorer) IsIgnored(path string, isDir bool) bool {
+	path = me.abs(path)
+	rel, err := filepath.Rel(me.RootDirPath, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	dirPath := me.RootDirPath
+	names := strings.Split(rel, string(filepath.Separator))
+	for _, name := range names[:len(names)-1] {
+		if dirPath = filepath.Join(dirPath, name); me.ignores(dirPath, true) {
+			return true
+		}
+	}
+	return me.ignores(path, isDir)
+}
+
+//	Returns whether the specified ignore-file `name` is one of `me.FileNames`.
+func (me *Ignorer) IsIgnoreFile(name string) bool {
+	for _, fn := range me.FileNames {
+		if fn == name {
+			return true
+		}
+	}
+	return false
+}
+
+//	Like `IsIgnored`, but without checking ancestor directories of `path` (as when walking, which
+//	doesn't descend into ignored directories anyway). `path` must be absolute.
+func (me *Ignorer) ignores(path string, isDir bool) bool {
+	if len(path) <= len(me.RootDirPath) || !strings.HasPrefix(path, me.RootDirPath) ||
+		(path[len(me.RootDirPath)] != filepath.Separator && !strings.HasSuffix(me.RootDirPath, string(filepath.Separator))) {
+		return false
+	}
+	for dirPath := filepath.Dir(path); len(dirPath) >= len(me.RootDirPath); dirPath = filepath.Dir(dirPath) {
+		if rules := me.dirRules(dirPath); len(rules) > 0 {
+			if rel, err := filepath.Rel(dirPath, path); err == nil {
+				rel = filepath.ToSlash(rel)
+				for i := len(rules) - 1; i >= 0; i-- {
+					if rules[i].isMatch(rel, isDir) {
+						return !rules[i].negated
+					}
+				}
+			}
+		}
+		if dirPath == me.RootDirPath || dirPath == filepath.Dir(dirPath) {
+			break
+		}
+	}
+	return false
+}
+
+func (me *Ignorer) dirRules(dirPath string) (rules []ignoreRule) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	rules, loaded := me.rules[dirPath]
+	if !loaded {
+		for _, fileName := range me.FileNames {
+			if data, err := fsReadFile(fsOrOs(me.Fs), filepath.Join(dirPath, fileName)); err == nil {
+				rules = append(rules, ignoreRules(ParseIgnoreLines(string(data)))...)
+			}
+		}
+		rules = append(rules, me.extras[dirPath]...)
+		if me.rules == nil {
+			me.rules = map[string][]ignoreRule{}
+		}
+		me.rules[dirPath] = rules
+	}
+	return
+}
+
+func (me *Ignorer) abs(path string) string {
+	if !filepath.IsAbs(path) {
+		if abspath, err := filepath.Abs(path); err == nil {
+			path = abspath
+		}
+	}
+	return filepath.Clean(path)
+}
+
+//	Returns the patterns in the specified ignore-file source: all lines except blank and `#` comment
+//	lines, with trailing spaces removed (unless `\`-escaped) and a leading `\#` unescaped.
+func ParseIgnoreLines(src string) (patterns []string) {
+	for _, ln := range strings.Split(src, "\n") {
+		ln = strings.TrimRight(ln, "\r")
+		for strings.HasSuffix(ln, " ") && !strings.HasSuffix(ln, "\\ ") {
+			ln = ln[:len(ln)-1]
+		}
+		if len(ln) > 0 && ln[0] != '#' {
+			if strings.HasPrefix(ln, "\\#") {
+				ln = ln[1:]
+			}
+			patterns = append(patterns, ln)
+		}
+	}
+	return
+}
+
+func ignoreRules(patterns []string) (rules []ignoreRule) {
+	for _, pattern := range patterns {
+		var rule ignoreRule
+		if strings.HasPrefix(pattern, "!") {
+			rule.negated, pattern = true, pattern[1:]
+		} else if strings.HasPrefix(pattern, "\\!") {
+			pattern = pattern[1:]
+		}
+		if strings.HasSuffix(pattern, "/") {
+			rule.dirOnly, pattern = true, strings.TrimRight(pattern, "/")
+		}
+		if len(pattern) == 0 {
+			continue
+		}
+		if strings.Contains(pattern, "/") {
+			pattern = strings.TrimPrefix(pattern, "/")
+		} else {
+			pattern = "**/" + pattern
+		}
+		if glob, err := ustr.GlobRegexp(ignoreGlobEscapeBraces(pattern)); err == nil {
+			rule.glob = glob
+			rules = append(rules, rule)
+		}
+	}
+	return
+}
+
+//	Ignore files have no `{a,b}` alternatives, so braces outside of `[`...`]` are taken literally.
+func ignoreGlobEscapeBraces(pattern string) string {
+	if !strings.ContainsAny(pattern, "{}") {
+		return pattern
+	}
+	var buf strings.Builder
+	inclass := false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i < len(pattern)-1:
+			buf.WriteString(pattern[i : i+2])
+			i++
+			continue
+		case c == '[' && !inclass:
+			inclass = true
+		case c == ']' && inclass:
+			inclass = false
+		case (c == '{' || c == '}') && !inclass:
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(pattern[i])
+	}
+	return buf.String()
+}
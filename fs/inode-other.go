@@ -0,0 +1,12 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package ufs
+
+import (
+	"os"
+)
+
+//	Always `0` on this platform, as `os.FileInfo.Sys` carries no inode number here.
+func fileInode(fileInfo os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,16 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package ufs
+
+import (
+	"os"
+	"syscall"
+)
+
+//	Returns the inode number of the file described by `fileInfo` (as returned by `os.Stat`).
+func fileInode(fileInfo os.FileInfo) uint64 {
+	if st, ok := fileInfo.Sys().(*syscall.Stat_t); ok && st != nil {
+		return uint64(st.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package ufs
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//	Blocks until an exclusive advisory lock for `filePath` is obtained, then returns the
+//	`unlock` func to release it. On this platform, the lock is the existence of the
+//	`LockFilePath(filePath)` side-car file, created exclusively and removed by `unlock`.
+//	Only processes that also use `LockFile` are kept out. A side-car file gone stale
+//	(see `LockFileStaleAfter`) is removed, an unrecoverably stuck one can be via `os.Remove`.
+func LockFile(filePath string) (unlock func() error, err error) {
+	var (
+		file *os.File
+		stat os.FileInfo
+	)
+	lockfilepath, staleafter := LockFilePath(filePath), LockFileStaleAfter
+	for {
+		if file, err = os.OpenFile(lockfilepath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666); err == nil {
+			file.Close()
+			var once sync.Once
+			stop := make(chan struct{})
+			if staleafter > 0 {
+				go lockFileKeepFresh(lockfilepath, staleafter/4, stop)
+			}
+			unlock = func() (err error) {
+				once.Do(func() { close(stop); err = os.Remove(lockfilepath) })
+				return
+			}
+			return
+		} else if !os.IsExist(err) {
+			return
+		} else if stat, err = os.Stat(lockfilepath); staleafter > 0 && err == nil && time.Since(stat.ModTime()) > staleafter {
+			if err = lockFileBreakStale(lockfilepath, stat); err == nil {
+				continue
+			}
+			return
+		}
+		time.Sleep(LockFilePollInterval)
+	}
+}
+
+//	Removes the `stale` side-car file at `lockFilePath` --- unless it was replaced or touched since, as by another
+//	`LockFile` caller having broken it and taken the lock in the meantime. So it is first renamed (atomically) to a
+//	unique name and checked there, to be put back in place if it turns out to be a fresh lock file.
+func lockFileBreakStale(lockFilePath string, stale os.FileInfo) (err error) {
+	tmppath := lockFilePath + "." + strconv.Itoa(os.Getpid()) + "." + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err = os.Rename(lockFilePath, tmppath); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	if stat, _ := os.Stat(tmppath); stat == nil || !(os.SameFile(stat, stale) && stat.ModTime().Equal(stale.ModTime())) {
+		if err = os.Link(tmppath, lockFilePath); err == nil || os.IsExist(err) {
+			err = nil
+		} else if err = os.Rename(tmppath, lockFilePath); err == nil {
+			return
+		}
+	}
+	if errrm := os.Remove(tmppath); err == nil && !os.IsNotExist(errrm) {
+		err = errrm
+	}
+	return
+}
+
+func lockFileKeepFresh(lockFilePath string, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			os.Chtimes(lockFilePath, now, now)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package ufs
+
+import (
+	"os"
+	"syscall"
+)
+
+//	Blocks until an exclusive advisory lock for `filePath` is obtained, then returns the
+//	`unlock` func to release it. The lock is held on a `LockFilePath(filePath)` side-car
+//	file (via `flock`) rather than on `filePath` itself, so that it survives `filePath`
+//	being replaced by `SaveToFileAtomic` and the like. Only processes that also use
+//	`LockFile` (or `flock` that side-car file) are kept out.
+func LockFile(filePath string) (unlock func() error, err error) {
+	var file *os.File
+	if file, err = os.OpenFile(LockFilePath(filePath), os.O_RDWR|os.O_CREATE, 0666); err == nil {
+		for err = syscall.EINTR; err == syscall.EINTR; {
+			err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+		}
+		if err != nil {
+			file.Close()
+		} else {
+			unlock = func() (err error) {
+				err = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+				if errclose := file.Close(); err == nil {
+					err = errclose
+				}
+				return
+			}
+		}
+	}
+	return
+}
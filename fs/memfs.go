@@ -0,0 +1,548 @@
+package ufs
+
+import (
+	"archive/zip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metaleap/go-util"
+)
+
+var (
+	errFsNotDir   = umisc.E("not a directory")
+	errFsIsDir    = umisc.E("is a directory")
+	errFsNotEmpty = umisc.E("directory not empty")
+	errFsLoop     = umisc.E("too many levels of symbolic links")
+)
+
+//	An `Fs` kept entirely in memory, for tests and scratch work: starts out as an empty root directory. All paths
+//	are taken as absolute (`"a/b"` is `"/a/b"`), and on Windows, volume names are ignored. Supports directories,
+//	regular files and symbolic links, with their permissions and modification times. Safe for concurrent use.
+type MemFs struct {
+	mutex    sync.Mutex
+	root     *memNode
+	readOnly bool
+}
+
+type memNode struct {
+	name     string
+	mode     os.FileMode
+	modTime  time.Time
+	data     []byte
+	link     string
+	children map[string]*memNode
+	zipFile  *zip.File // for `ZipFs`: `data` is read from it on the first `Open`
+}
+
+//	Returns a new, empty `MemFs`.
+func NewMemFs() *MemFs {
+	return &MemFs{root: &memNode{name: "/", mode: os.ModeDir | 0755, modTime: time.Now(), children: map[string]*memNode{}}}
+}
+
+func (me *memNode) info() *memNodeInfo {
+	size := int64(len(me.data))
+	if me.zipFile != nil { // not yet decompressed
+		size = int64(me.zipFile.UncompressedSize64)
+	}
+	return &memNodeInfo{name: me.name, size: size, mode: me.mode, modTime: me.modTime, node: me}
+}
+
+//	A snapshot of a `memNode`, as returned by `MemFs.Stat`.
+type memNodeInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	node    *memNode
+}
+
+func (me *memNodeInfo) Name() string       { return me.name }
+func (me *memNodeInfo) Size() int64        { return me.size }
+func (me *memNodeInfo) Mode() os.FileMode  { return me.mode }
+func (me *memNodeInfo) ModTime() time.Time { return me.modTime }
+func (me *memNodeInfo) IsDir() bool        { return me.mode.IsDir() }
+func (me *memNodeInfo) Sys() interface{}   { return me.node }
+
+func memFsParts(name string) []string {
+	name = filepath.ToSlash(name)
+	if vol := filepath.VolumeName(name); len(vol) > 0 {
+		name = name[len(vol):]
+	}
+	return strings.Split(strings.Trim(path.Clean("/"+name), "/"), "/")
+}
+
+func memFsErr(op string, name string, err error) error {
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+//	Resolves `name` (following symbolic links on the way, and also at the end if `followLast`) to its `parent`
+//	directory and its `node`, which is `nil` if it doesn't exist (but `parent` does) and then is to be created
+//	as `base`, the final resolved name in `parent`. Must be called with `me.mutex` locked.
+func (me *MemFs) lookup(op string, name string, followLast bool) (parent *memNode, node *memNode, base string, err error) {
+	stack, parts, follows := []*memNode{me.root}, memFsParts(name), 0
+	for i := 0; i < len(parts); i++ {
+		part, dir := parts[i], stack[len(stack)-1]
+		if part == "" || part == "." {
+			continue
+		} else if part == ".." {
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		} else if !dir.mode.IsDir() {
+			return nil, nil, "", memFsErr(op, name, errFsNotDir)
+		}
+		child, islast := dir.children[part], i == len(parts)-1
+		if child != nil && child.mode&os.ModeSymlink != 0 && (followLast || !islast) {
+			if follows++; follows > 40 {
+				return nil, nil, "", memFsErr(op, name, errFsLoop)
+			}
+			if path.IsAbs(filepath.ToSlash(child.link)) {
+				stack = stack[:1]
+			}
+			parts, i = append(strings.Split(filepath.ToSlash(child.link), "/"), parts[i+1:]...), -1
+			continue
+		} else if islast {
+			return dir, child, part, nil
+		} else if child == nil {
+			return nil, nil, "", memFsErr(op, name, os.ErrNotExist)
+		}
+		stack = append(stack, child)
+	}
+	if node = stack[len(stack)-1]; len(stack) > 1 {
+		parent = stack[len(stack)-2]
+	}
+	base = node.name
+	return
+}
+
+//	Like `lookup`, but fails if `node` doesn't exist.
+func (me *MemFs) find(op string, name string, followLast bool) (parent *memNode, node *memNode, err error) {
+	if parent, node, _, err = me.lookup(op, name, followLast); err == nil && node == nil {
+		err = memFsErr(op, name, os.ErrNotExist)
+	}
+	return
+}
+
+func (me *MemFs) writable(op string, name string) error {
+	if me.readOnly {
+		return memFsErr(op, name, ErrFsReadOnly)
+	}
+	return nil
+}
+
+func (me *MemFs) Open(name string) (FsFile, error) {
+	return me.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (me *MemFs) OpenFile(name string, flag int, perm os.FileMode) (FsFile, error) {
+	writing := flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0
+	if writing {
+		if err := me.writable("open", name); err != nil {
+			return nil, err
+		}
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	// with `O_EXCL`, a symbolic link isn't followed but makes it fail, as with `os`
+	parent, node, base, err := me.lookup("open", name, flag&(os.O_CREATE|os.O_EXCL) != os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return nil, err
+	} else if node == nil && flag&os.O_CREATE == 0 {
+		return nil, memFsErr("open", name, os.ErrNotExist)
+	} else if node != nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, memFsErr("open", name, os.ErrExist)
+	} else if node != nil && node.mode.IsDir() && writing {
+		return nil, memFsErr("open", name, errFsIsDir)
+	} else if node == nil {
+		node = &memNode{name: base, mode: perm.Perm(), modTime: time.Now()}
+		parent.children[node.name] = node
+	} else if flag&os.O_TRUNC != 0 {
+		node.data, node.zipFile, node.modTime = nil, nil, time.Now()
+	}
+	if node.zipFile != nil {
+		if err = me.unzip(node); err != nil {
+			return nil, memFsErr("open", name, err)
+		}
+	}
+	return &memFile{fs: me, node: node, name: name, flag: flag}, nil
+}
+
+func (me *MemFs) unzip(node *memNode) (err error) {
+	var src io.ReadCloser
+	if src, err = node.zipFile.Open(); err == nil {
+		defer src.Close()
+		if node.data, err = ioutil.ReadAll(src); err == nil {
+			node.zipFile = nil
+		}
+	}
+	return
+}
+
+func (me *MemFs) Stat(name string) (os.FileInfo, error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	_, node, err := me.find("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info := node.info()
+	info.name = filepath.Base(name) // as per `os.Stat`, not the name of a symbolic link's target
+	return info, nil
+}
+
+func (me *MemFs) Lstat(name string) (os.FileInfo, error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	_, node, err := me.find("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	info := node.info()
+	info.name = filepath.Base(name)
+	return info, nil
+}
+
+func (me *MemFs) ReadDir(name string) ([]os.DirEntry, error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	_, node, err := me.find("readdir", name, true)
+	if err != nil {
+		return nil, err
+	} else if !node.mode.IsDir() {
+		return nil, memFsErr("readdir", name, errFsNotDir)
+	}
+	infos := make([]os.FileInfo, 0, len(node.children))
+	for _, child := range node.children {
+		infos = append(infos, child.info())
+	}
+	return fsDirEntries(infos), nil
+}
+
+func (me *MemFs) Mkdir(name string, perm os.FileMode) error {
+	if err := me.writable("mkdir", name); err != nil {
+		return err
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	parent, node, base, err := me.lookup("mkdir", name, false)
+	if err != nil {
+		return err
+	} else if node != nil {
+		return memFsErr("mkdir", name, os.ErrExist)
+	}
+	node = &memNode{name: base, mode: os.ModeDir | perm.Perm(), modTime: time.Now(), children: map[string]*memNode{}}
+	parent.children[node.name], parent.modTime = node, node.modTime
+	return nil
+}
+
+func (me *MemFs) MkdirAll(name string, perm os.FileMode) error {
+	if info, err := me.Stat(name); err == nil {
+		if !info.IsDir() {
+			return memFsErr("mkdir", name, errFsNotDir)
+		}
+		return nil
+	}
+	parts := memFsParts(name)
+	for i := range parts {
+		dirpath := "/" + strings.Join(parts[:i+1], "/")
+		if err := me.Mkdir(dirpath, perm); err != nil && !os.IsExist(err) {
+			return err
+		} else if info, err := me.Stat(dirpath); err != nil {
+			return err
+		} else if !info.IsDir() {
+			return memFsErr("mkdir", dirpath, errFsNotDir)
+		}
+	}
+	return nil
+}
+
+func (me *MemFs) Remove(name string) error {
+	if err := me.writable("remove", name); err != nil {
+		return err
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	parent, node, err := me.find("remove", name, false)
+	if err != nil {
+		return err
+	} else if parent == nil {
+		return memFsErr("remove", name, os.ErrInvalid)
+	} else if len(node.children) > 0 {
+		return memFsErr("remove", name, errFsNotEmpty)
+	}
+	delete(parent.children, node.name)
+	parent.modTime = time.Now()
+	return nil
+}
+
+func (me *MemFs) RemoveAll(name string) error {
+	if err := me.writable("removeall", name); err != nil {
+		return err
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	parent, node, _, err := me.lookup("removeall", name, false)
+	if err != nil || node == nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return err
+	} else if parent == nil {
+		node.children = map[string]*memNode{}
+	} else {
+		delete(parent.children, node.name)
+		parent.modTime = time.Now()
+	}
+	return nil
+}
+
+func (me *MemFs) Rename(oldName string, newName string) error {
+	if err := me.writable("rename", oldName); err != nil {
+		return err
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	oldparent, node, err := me.find("rename", oldName, false)
+	if err != nil {
+		return err
+	}
+	newparent, existing, newbase, err := me.lookup("rename", newName, false)
+	if err != nil {
+		return err
+	} else if oldparent == nil || newparent == nil {
+		return memFsErr("rename", oldName, os.ErrInvalid)
+	} else if existing == node {
+		return nil
+	} else if existing != nil && existing.mode.IsDir() && (!node.mode.IsDir() || len(existing.children) > 0) {
+		return memFsErr("rename", newName, errFsNotEmpty)
+	} else if existing != nil && !existing.mode.IsDir() && node.mode.IsDir() {
+		return memFsErr("rename", newName, errFsNotDir)
+	}
+	if node.mode.IsDir() { // not into itself
+		for _, dir := range me.ancestors(newparent) {
+			if dir == node {
+				return memFsErr("rename", newName, os.ErrInvalid)
+			}
+		}
+	}
+	delete(oldparent.children, node.name)
+	node.name = newbase
+	newparent.children[node.name] = node
+	oldparent.modTime, newparent.modTime = time.Now(), time.Now()
+	return nil
+}
+
+//	Returns `node` and all its ancestor directories. Must be called with `me.mutex` locked.
+func (me *MemFs) ancestors(node *memNode) (dirs []*memNode) {
+	var find func(*memNode, []*memNode) bool
+	find = func(dir *memNode, chain []*memNode) bool {
+		if chain = append(chain, dir); dir == node {
+			dirs = chain
+			return true
+		}
+		for _, child := range dir.children {
+			if child.mode.IsDir() && find(child, chain) {
+				return true
+			}
+		}
+		return false
+	}
+	find(me.root, nil)
+	return
+}
+
+func (me *MemFs) Chmod(name string, mode os.FileMode) error {
+	if err := me.writable("chmod", name); err != nil {
+		return err
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	_, node, err := me.find("chmod", name, true)
+	if err == nil {
+		node.mode = (node.mode &^ os.ModePerm) | mode.Perm()
+	}
+	return err
+}
+
+func (me *MemFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := me.writable("chtimes", name); err != nil {
+		return err
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	_, node, err := me.find("chtimes", name, true)
+	if err == nil {
+		node.modTime = mtime
+	}
+	return err
+}
+
+func (me *MemFs) Symlink(oldName string, newName string) error {
+	if err := me.writable("symlink", newName); err != nil {
+		return err
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	parent, node, base, err := me.lookup("symlink", newName, false)
+	if err != nil {
+		return err
+	} else if node != nil {
+		return memFsErr("symlink", newName, os.ErrExist)
+	}
+	node = &memNode{name: base, mode: os.ModeSymlink | 0777, modTime: time.Now(), link: oldName}
+	parent.children[node.name], parent.modTime = node, node.modTime
+	return nil
+}
+
+func (me *MemFs) Readlink(name string) (string, error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	_, node, err := me.find("readlink", name, false)
+	if err != nil {
+		return "", err
+	} else if node.mode&os.ModeSymlink == 0 {
+		return "", memFsErr("readlink", name, os.ErrInvalid)
+	}
+	return node.link, nil
+}
+
+//	An open file in a `MemFs`.
+type memFile struct {
+	fs     *MemFs
+	node   *memNode
+	name   string
+	flag   int
+	offset int64
+	closed bool
+}
+
+func (me *memFile) check(op string, write bool) error {
+	if me.closed {
+		return memFsErr(op, me.name, os.ErrClosed)
+	} else if write && me.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return memFsErr(op, me.name, os.ErrPermission)
+	} else if !write && me.flag&os.O_WRONLY != 0 {
+		return memFsErr(op, me.name, os.ErrPermission)
+	} else if !write && me.node.mode.IsDir() {
+		return memFsErr(op, me.name, errFsIsDir)
+	}
+	return nil
+}
+
+func (me *memFile) Name() string {
+	return me.name
+}
+
+func (me *memFile) Read(p []byte) (n int, err error) {
+	me.fs.mutex.Lock()
+	defer me.fs.mutex.Unlock()
+	if err = me.check("read", false); err == nil {
+		n, err = me.readAt(p, me.offset)
+		me.offset += int64(n)
+	}
+	return
+}
+
+func (me *memFile) ReadAt(p []byte, off int64) (n int, err error) {
+	me.fs.mutex.Lock()
+	defer me.fs.mutex.Unlock()
+	if err = me.check("read", false); err == nil {
+		if n, err = me.readAt(p, off); err == nil && n < len(p) {
+			err = io.EOF
+		}
+	}
+	return
+}
+
+func (me *memFile) readAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, memFsErr("read", me.name, os.ErrInvalid)
+	} else if off >= int64(len(me.node.data)) {
+		return 0, io.EOF
+	}
+	return copy(p, me.node.data[off:]), nil
+}
+
+func (me *memFile) Write(p []byte) (n int, err error) {
+	me.fs.mutex.Lock()
+	defer me.fs.mutex.Unlock()
+	if err = me.check("write", true); err == nil {
+		if me.flag&os.O_APPEND != 0 {
+			me.offset = int64(len(me.node.data))
+		}
+		if end := me.offset + int64(len(p)); end > int64(len(me.node.data)) {
+			data := make([]byte, end, end+end/4)
+			copy(data, me.node.data)
+			me.node.data = data
+		}
+		n = copy(me.node.data[me.offset:], p)
+		me.offset += int64(n)
+		me.node.modTime = time.Now()
+	}
+	return
+}
+
+func (me *memFile) Seek(offset int64, whence int) (int64, error) {
+	me.fs.mutex.Lock()
+	defer me.fs.mutex.Unlock()
+	if me.closed {
+		return 0, memFsErr("seek", me.name, os.ErrClosed)
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += me.offset
+	case io.SeekEnd:
+		offset += int64(len(me.node.data))
+	}
+	if offset < 0 {
+		return 0, memFsErr("seek", me.name, os.ErrInvalid)
+	}
+	me.offset = offset
+	return offset, nil
+}
+
+func (me *memFile) Close() error {
+	me.fs.mutex.Lock()
+	defer me.fs.mutex.Unlock()
+	if me.closed {
+		return memFsErr("close", me.name, os.ErrClosed)
+	}
+	me.closed = true
+	return nil
+}
+
+func (me *memFile) Stat() (os.FileInfo, error) {
+	me.fs.mutex.Lock()
+	defer me.fs.mutex.Unlock()
+	if me.closed {
+		return nil, memFsErr("stat", me.name, os.ErrClosed)
+	}
+	return me.node.info(), nil
+}
+
+func (me *memFile) Sync() error {
+	return nil
+}
+
+func (me *memFile) Truncate(size int64) (err error) {
+	me.fs.mutex.Lock()
+	defer me.fs.mutex.Unlock()
+	if err = me.check("truncate", true); err == nil {
+		if size < 0 {
+			return memFsErr("truncate", me.name, os.ErrInvalid)
+		} else if size <= int64(len(me.node.data)) {
+			me.node.data = me.node.data[:size]
+		} else {
+			me.node.data = append(me.node.data, make([]byte, size-int64(len(me.node.data)))...)
+		}
+		me.node.modTime = time.Now()
+	}
+	return
+}
@@ -0,0 +1,443 @@
+package ufs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//	A copy-on-write `Fs` that layers `Upper` over a `Base` that it never modifies: reads see the contents of
+//	`Upper` where present and of `Base` elsewhere, writes first copy the affected file (or directory, or
+//	symbolic link) from `Base` up into `Upper`, and removals of what exists in `Base` are recorded in memory as
+//	"whiteouts" that hide it from then on. Typical for tests or dry runs: `NewOverlayFs(OsFs, nil)`. Safe for
+//	concurrent use if `Base` and `Upper` are. All changes should go through the `OverlayFs`, not directly to `Upper`.
+type OverlayFs struct {
+	Base  Fs
+	Upper Fs
+
+	mutex     sync.Mutex
+	whiteouts map[string]bool // removed from `Base`
+	opaque    map[string]bool // re-created in `Upper` after removal, hiding all of `Base` below
+}
+
+//	Returns a new `OverlayFs` for `base` (or `OsFs` if `nil`) and `upper` (or a new `MemFs` if `nil`).
+func NewOverlayFs(base Fs, upper Fs) *OverlayFs {
+	if upper == nil {
+		upper = NewMemFs()
+	}
+	return &OverlayFs{Base: fsOrOs(base), Upper: upper, whiteouts: map[string]bool{}, opaque: map[string]bool{}}
+}
+
+//	Returns whether `name` is hidden in `me.Base`, by a whiteout of itself or of an ancestor directory,
+//	or by an opaque ancestor directory. Must be called with `me.mutex` locked, as must all lower-case methods.
+func (me *OverlayFs) baseHidden(name string) bool {
+	for p := name; ; p = filepath.Dir(p) {
+		if me.whiteouts[p] || (p != name && me.opaque[p]) {
+			return true
+		} else if parent := filepath.Dir(p); parent == p {
+			return false
+		}
+	}
+}
+
+func (me *OverlayFs) inUpper(name string) bool {
+	_, err := me.Upper.Lstat(name)
+	return err == nil
+}
+
+func (me *OverlayFs) lstat(name string) (info os.FileInfo, err error) {
+	if info, err = me.Upper.Lstat(name); err == nil || !os.IsNotExist(err) {
+		return
+	} else if me.baseHidden(name) {
+		return nil, &os.PathError{Op: "lstat", Path: name, Err: os.ErrNotExist}
+	}
+	return me.Base.Lstat(name)
+}
+
+func (me *OverlayFs) readlink(name string) (string, error) {
+	if me.inUpper(name) {
+		return me.Upper.Readlink(name)
+	} else if me.baseHidden(name) {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: os.ErrNotExist}
+	}
+	return me.Base.Readlink(name)
+}
+
+//	Follows `name` if it's a symbolic link (possibly across layers), returning the final target and its `info`.
+func (me *OverlayFs) resolve(name string) (target string, info os.FileInfo, err error) {
+	target = name
+	for i := 0; i < 40; i++ {
+		var link string
+		if info, err = me.lstat(target); err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return
+		} else if link, err = me.readlink(target); err != nil {
+			return
+		} else if filepath.IsAbs(link) {
+			target = me.clean(link)
+		} else {
+			target = me.clean(filepath.Join(filepath.Dir(target), link))
+		}
+	}
+	return name, nil, &os.PathError{Op: "stat", Path: name, Err: errFsLoop}
+}
+
+//	Returns `name` cleaned and with all symbolic links in its directory part followed, so that
+//	neither layer has to follow symbolic links that might (also) be in the other one.
+func (me *OverlayFs) clean(name string) string {
+	name = filepath.Clean(name)
+	if parent := filepath.Dir(name); parent != name {
+		if target, _, err := me.resolve(me.clean(parent)); err == nil {
+			parent = target
+		}
+		return filepath.Join(parent, filepath.Base(name))
+	}
+	return name
+}
+
+//	Ensures `name` (which must exist) is in `me.Upper`, creating its ancestor directories there as needed.
+//	Directories are copied up without their contents, those keep coming from `me.Base` until copied up themselves.
+func (me *OverlayFs) copyUp(name string) (err error) {
+	var info os.FileInfo
+	if me.inUpper(name) {
+		return nil
+	} else if info, err = me.lstat(name); err != nil {
+		return
+	} else if err = me.copyUpParent(name); err != nil {
+		return
+	}
+	switch mode := info.Mode(); {
+	case mode.IsDir():
+		err = me.Upper.Mkdir(name, mode.Perm())
+	case mode&os.ModeSymlink != 0:
+		var link string
+		if link, err = me.Base.Readlink(name); err == nil {
+			err = me.Upper.Symlink(link, name)
+		}
+		return
+	default:
+		var src, dst FsFile
+		if src, err = me.Base.Open(name); err != nil {
+			return
+		}
+		defer src.Close()
+		if dst, err = me.Upper.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()); err != nil {
+			return
+		}
+		_, err = io.Copy(dst, src)
+		if errclose := dst.Close(); err == nil {
+			err = errclose
+		}
+	}
+	if err == nil {
+		err = me.Upper.Chtimes(name, info.ModTime(), info.ModTime())
+	}
+	return
+}
+
+func (me *OverlayFs) copyUpParent(name string) error {
+	if parent := filepath.Dir(name); parent != name {
+		return me.copyUp(parent)
+	}
+	return nil
+}
+
+//	Like `copyUp`, but for directories also copies up all their contents, recursively.
+func (me *OverlayFs) copyUpAll(name string) (err error) {
+	var info os.FileInfo
+	var entries []os.DirEntry
+	if err = me.copyUp(name); err == nil {
+		if info, err = me.lstat(name); err == nil && info.IsDir() {
+			if entries, err = me.readDir(name); err == nil {
+				for _, entry := range entries {
+					if err = me.copyUpAll(filepath.Join(name, entry.Name())); err != nil {
+						break
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+//	To be called after creating `name` in `me.Upper`: if it was removed from `me.Base` before, it now hides all of `me.Base` below it.
+func (me *OverlayFs) unwhite(name string) {
+	if me.whiteouts[name] {
+		delete(me.whiteouts, name)
+		me.opaque[name] = true
+	}
+}
+
+//	Records that `name` (and all below it) was removed, forgetting earlier records below it.
+func (me *OverlayFs) whiteout(name string) {
+	prefix := name + string(filepath.Separator)
+	for _, m := range []map[string]bool{me.whiteouts, me.opaque} {
+		for p := range m {
+			if p == name || strings.HasPrefix(p, prefix) {
+				delete(m, p)
+			}
+		}
+	}
+	me.whiteouts[name] = true
+}
+
+func (me *OverlayFs) readDir(name string) ([]os.DirEntry, error) {
+	target, info, err := me.resolve(name)
+	if err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: errFsNotDir}
+	}
+	merged := map[string]os.DirEntry{}
+	if me.inUpper(target) {
+		entries, err := me.Upper.ReadDir(target)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			merged[entry.Name()] = entry
+		}
+	}
+	if !(me.baseHidden(target) || me.opaque[target]) {
+		if baseinfo, err := me.Base.Stat(target); err == nil && baseinfo.IsDir() {
+			entries, err := me.Base.ReadDir(target)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if _, exists := merged[entry.Name()]; !(exists || me.whiteouts[filepath.Join(target, entry.Name())]) {
+					merged[entry.Name()] = entry
+				}
+			}
+		}
+	}
+	entries := make([]os.DirEntry, 0, len(merged))
+	for _, entry := range merged {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i int, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+//	Fails if `name` exists, or if its parent directory doesn't; otherwise copies up the latter.
+func (me *OverlayFs) prepareCreate(op string, name string) error {
+	if _, err := me.lstat(name); err == nil {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrExist}
+	} else if parent := filepath.Dir(name); parent != name {
+		if _, info, err := me.resolve(parent); err != nil {
+			return err
+		} else if !info.IsDir() {
+			return &os.PathError{Op: op, Path: name, Err: errFsNotDir}
+		}
+	}
+	return me.copyUpParent(name)
+}
+
+func (me *OverlayFs) Open(name string) (FsFile, error) {
+	return me.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (me *OverlayFs) OpenFile(name string, flag int, perm os.FileMode) (FsFile, error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	target, _, err := me.resolve(me.clean(name))
+	if err != nil && !(os.IsNotExist(err) && flag&os.O_CREATE != 0) {
+		return nil, err
+	} else if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		if me.inUpper(target) {
+			return me.Upper.OpenFile(target, flag, perm)
+		}
+		return me.Base.OpenFile(target, flag, perm)
+	} else if err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	} else if err == nil {
+		if err = me.copyUp(target); err != nil {
+			return nil, err
+		}
+		return me.Upper.OpenFile(target, flag, perm)
+	} else if err = me.prepareCreate("open", target); err != nil {
+		return nil, err
+	}
+	file, err := me.Upper.OpenFile(target, flag, perm)
+	if err == nil {
+		me.unwhite(target)
+	}
+	return file, err
+}
+
+func (me *OverlayFs) Stat(name string) (os.FileInfo, error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	_, info, err := me.resolve(me.clean(name))
+	if err == nil && info.Name() != filepath.Base(name) { // as per `os.Stat`, not the name of a symbolic link's target
+		info = &fsNamedInfo{FileInfo: info, name: filepath.Base(name)}
+	}
+	return info, err
+}
+
+func (me *OverlayFs) Lstat(name string) (os.FileInfo, error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return me.lstat(me.clean(name))
+}
+
+func (me *OverlayFs) ReadDir(name string) ([]os.DirEntry, error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return me.readDir(me.clean(name))
+}
+
+func (me *OverlayFs) Mkdir(name string, perm os.FileMode) (err error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	name = me.clean(name)
+	if err = me.prepareCreate("mkdir", name); err == nil {
+		if err = me.Upper.Mkdir(name, perm); err == nil {
+			me.unwhite(name)
+		}
+	}
+	return
+}
+
+func (me *OverlayFs) MkdirAll(name string, perm os.FileMode) error {
+	name = filepath.Clean(name)
+	if info, err := me.Stat(name); err == nil {
+		if !info.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: errFsNotDir}
+		}
+		return nil
+	}
+	if parent := filepath.Dir(name); parent != name {
+		if err := me.MkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	if err := me.Mkdir(name, perm); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (me *OverlayFs) Remove(name string) error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	name = me.clean(name)
+	if info, err := me.lstat(name); err != nil {
+		return err
+	} else if info.IsDir() {
+		if entries, err := me.readDir(name); err != nil {
+			return err
+		} else if len(entries) > 0 {
+			return &os.PathError{Op: "remove", Path: name, Err: errFsNotEmpty}
+		}
+	}
+	if me.inUpper(name) {
+		if err := me.Upper.Remove(name); err != nil {
+			return err
+		}
+	}
+	me.whiteout(name)
+	return nil
+}
+
+func (me *OverlayFs) RemoveAll(name string) error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	name = me.clean(name)
+	if _, err := me.lstat(name); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return err
+	}
+	if err := me.Upper.RemoveAll(name); err != nil {
+		return err
+	}
+	me.whiteout(name)
+	return nil
+}
+
+func (me *OverlayFs) Rename(oldName string, newName string) error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	oldName, newName = me.clean(oldName), me.clean(newName)
+	info, err := me.lstat(oldName)
+	if err != nil {
+		return err
+	} else if oldName == newName {
+		return nil
+	}
+	if existing, err := me.lstat(newName); err == nil {
+		if existing.IsDir() && !info.IsDir() {
+			return &os.PathError{Op: "rename", Path: newName, Err: errFsIsDir}
+		} else if info.IsDir() && !existing.IsDir() {
+			return &os.PathError{Op: "rename", Path: newName, Err: errFsNotDir}
+		} else if existing.IsDir() {
+			if entries, err := me.readDir(newName); err != nil {
+				return err
+			} else if len(entries) > 0 {
+				return &os.PathError{Op: "rename", Path: newName, Err: errFsNotEmpty}
+			}
+		}
+		if err = me.Upper.RemoveAll(newName); err != nil {
+			return err
+		}
+	} else if err = me.prepareCreate("rename", newName); err != nil {
+		return err
+	}
+	if err = me.copyUpAll(oldName); err == nil {
+		if err = me.copyUpParent(newName); err == nil {
+			if err = me.Upper.Rename(oldName, newName); err == nil {
+				me.whiteout(oldName)
+				me.whiteout(newName)
+				me.unwhite(newName)
+			}
+		}
+	}
+	return err
+}
+
+func (me *OverlayFs) Chmod(name string, mode os.FileMode) error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	target, _, err := me.resolve(me.clean(name))
+	if err == nil {
+		if err = me.copyUp(target); err == nil {
+			err = me.Upper.Chmod(target, mode)
+		}
+	}
+	return err
+}
+
+func (me *OverlayFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	target, _, err := me.resolve(me.clean(name))
+	if err == nil {
+		if err = me.copyUp(target); err == nil {
+			err = me.Upper.Chtimes(target, atime, mtime)
+		}
+	}
+	return err
+}
+
+func (me *OverlayFs) Symlink(oldName string, newName string) (err error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	newName = me.clean(newName)
+	if err = me.prepareCreate("symlink", newName); err == nil {
+		if err = me.Upper.Symlink(oldName, newName); err == nil {
+			me.unwhite(newName)
+		}
+	}
+	return
+}
+
+func (me *OverlayFs) Readlink(name string) (string, error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return me.readlink(me.clean(name))
+}
@@ -0,0 +1,33 @@
+// +build linux,!appengine
+
+package ufs
+
+import (
+	"os"
+	"syscall"
+)
+
+//	The `FICLONE` `ioctl` request of Linux 4.5+.
+const reflinkFiClone = 0x40049409
+
+//	Creates `dstFilePath` as a copy-on-write clone of `srcFilePath`, or fails if the file system can't.
+func reflinkFile(srcFilePath string, dstFilePath string, perm os.FileMode) (err error) {
+	var src, dst *os.File
+	if src, err = os.Open(srcFilePath); err != nil {
+		return
+	}
+	defer src.Close()
+	if dst, err = os.OpenFile(dstFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm); err != nil {
+		return
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), reflinkFiClone, src.Fd()); errno != 0 {
+		err = &os.PathError{Op: "reflink", Path: dstFilePath, Err: errno}
+	}
+	if errclose := dst.Close(); err == nil {
+		err = errclose
+	}
+	if err != nil {
+		_ = os.Remove(dstFilePath)
+	}
+	return
+}
@@ -0,0 +1,16 @@
+// +build !linux appengine
+
+package ufs
+
+import (
+	"os"
+
+	"github.com/metaleap/go-util"
+)
+
+var errSyncReflinkUnsupported = umisc.E("reflinks not supported on this platform")
+
+//	Always fails on this platform, so that `Sync` copies instead.
+func reflinkFile(srcFilePath string, dstFilePath string, perm os.FileMode) error {
+	return &os.PathError{Op: "reflink", Path: dstFilePath, Err: errSyncReflinkUnsupported}
+}
@@ -0,0 +1,416 @@
+package ufs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/metaleap/go-util"
+)
+
+// The kind of a `SyncOp`.
+type SyncAction int
+
+const (
+	//	Creates a directory that exists in the source but not in the destination.
+	SyncMkdir SyncAction = iota
+
+	//	Copies a file that exists in the source but not in the destination.
+	SyncCopy
+
+	//	Replaces a destination file whose contents differ from the source file.
+	SyncUpdate
+
+	//	Updates only the permissions and modification time of a destination file or directory, whose contents are up to date.
+	SyncTouch
+
+	//	Creates or replaces a symbolic link in the destination, with the same target as in the source.
+	SyncSymlink
+
+	//	Removes a destination file or directory (with all its contents): either extraneous
+	//	(see `SyncOptions.DeleteExtraneous`) or in the way of a source item of another type.
+	SyncDelete
+)
+
+func (me SyncAction) String() string {
+	switch me {
+	case SyncMkdir:
+		return "mkdir"
+	case SyncCopy:
+		return "copy"
+	case SyncUpdate:
+		return "update"
+	case SyncTouch:
+		return "touch"
+	case SyncSymlink:
+		return "symlink"
+	case SyncDelete:
+		return "delete"
+	}
+	return "SyncAction(" + strconv.Itoa(int(me)) + ")"
+}
+
+// One step of a `Sync`, performed (or, with `SyncOptions.DryRun`, planned) in the destination.
+type SyncOp struct {
+	Action SyncAction
+
+	//	Relative to both the source and the destination directory.
+	Path string
+
+	//	For `SyncCopy` and `SyncUpdate`, the number of bytes to copy.
+	Size int64
+
+	mode    os.FileMode
+	modTime time.Time
+	link    string
+}
+
+// Such as `"update sub/file.txt (1234 bytes)"`, for reporting.
+func (me *SyncOp) String() string {
+	if me.Action == SyncCopy || me.Action == SyncUpdate {
+		return me.Action.String() + " " + me.Path + " (" + strconv.FormatInt(me.Size, 10) + " bytes)"
+	} else if me.Action == SyncSymlink {
+		return me.Action.String() + " " + me.Path + " -> " + me.link
+	}
+	return me.Action.String() + " " + me.Path
+}
+
+// The progress of a `Sync`, as reported to `SyncOptions.OnProgress`.
+type SyncProgress struct {
+	OpsDone    int
+	OpsTotal   int
+	BytesDone  int64
+	BytesTotal int64
+}
+
+// How `Sync` creates files in the destination.
+type SyncLinkMode int
+
+const (
+	//	Files are copied.
+	SyncLinkNone SyncLinkMode = iota
+
+	//	Files are hard-linked to the source files, or copied where that fails (such as across devices).
+	//	Destination files that already are the source files count as up to date, all others get replaced.
+	//	As both then share contents, permissions and modification times, don't modify either in place.
+	//	Requires `SyncOptions.SrcFs` and `SyncOptions.DstFs` to be `OsFs`.
+	SyncLinkHard
+
+	//	Files are cloned (copy-on-write, sharing storage until modified) where the file system supports
+	//	it (currently Btrfs, XFS and others supporting `FICLONE` on Linux), and copied elsewhere.
+	//	Requires `SyncOptions.SrcFs` and `SyncOptions.DstFs` to be `OsFs`.
+	SyncLinkReflink
+)
+
+// Options for `Sync`.
+type SyncOptions struct {
+	//	If `true`, files are compared by content (SHA-256 of both, but only if their sizes are equal)
+	//	rather than by size and modification time. Slower, but immune to clock and time-stamp issues.
+	HashContents bool
+
+	//	When comparing modification times, the difference up to which they still count as equal:
+	//	such as `2 * time.Second` for FAT destinations, or `time.Second` for coarse-grained file systems.
+	ModTimeWindow time.Duration
+
+	//	If `true`, items in the destination that don't exist in the source are removed.
+	DeleteExtraneous bool
+
+	//	If `true`, the destination is not modified: `Sync` only returns the `SyncOp`s it would perform.
+	DryRun bool
+
+	//	How files get created in the destination. Defaults to `SyncLinkNone`.
+	Link SyncLinkMode
+
+	//	If set, source items it ignores are neither synced nor (with `DeleteExtraneous`) removed from the destination.
+	Ignore *Ignorer
+
+	//	If set, called after each `SyncOp` performed (but not with `DryRun`).
+	OnProgress func(op *SyncOp, progress SyncProgress)
+
+	//	The file systems of the source and destination directories. If `nil`, `OsFs`.
+	SrcFs, DstFs Fs
+}
+
+// Mirrors the contents of `srcDirPath` into `dstDirPath` (which gets created if needed): only copies
+// new and changed files (see `SyncOptions.HashContents`), creates missing directories and symbolic links,
+// keeps permissions and modification times, and can remove extraneous items (see `SyncOptions.DeleteExtraneous`).
+// Files are written to a temporary file in their destination directory first, then renamed into place.
+// Returns the `SyncOp`s performed (or planned, with `SyncOptions.DryRun`), in order, up to the first `error`, if any.
+// `opts` may be `nil`. If `dstDirPath` is inside `srcDirPath`, it is not synced into itself.
+func Sync(srcDirPath string, dstDirPath string, opts *SyncOptions) (ops []SyncOp, err error) {
+	if opts == nil {
+		opts = &SyncOptions{}
+	}
+	me := &syncer{SyncOptions: opts, src: fsOrOs(opts.SrcFs), dst: fsOrOs(opts.DstFs), srcRoot: filepath.Clean(srcDirPath), dstRoot: filepath.Clean(dstDirPath), writable: map[string]os.FileMode{}}
+	if me.Link != SyncLinkNone && (me.src != OsFs || me.dst != OsFs) {
+		return nil, umisc.E("ufs.Sync: SyncOptions.Link requires SrcFs and DstFs to be OsFs")
+	}
+	if me.src == me.dst && me.src == OsFs {
+		me.srcRoot, me.dstRoot = fileStatesKey(me.srcRoot), fileStatesKey(me.dstRoot)
+	}
+
+	var srcinfo, dstinfo os.FileInfo
+	if srcinfo, err = me.src.Stat(me.srcRoot); err != nil {
+		return
+	} else if !srcinfo.IsDir() {
+		return nil, &os.PathError{Op: "sync", Path: srcDirPath, Err: errFsNotDir}
+	}
+	if dstinfo, err = me.dst.Stat(me.dstRoot); err != nil && !os.IsNotExist(err) {
+		return
+	} else if dstinfo != nil && !dstinfo.IsDir() {
+		return nil, &os.PathError{Op: "sync", Path: dstDirPath, Err: errFsNotDir}
+	} else if dstinfo == nil {
+		me.ops = append(me.ops, SyncOp{Action: SyncMkdir, Path: ".", mode: srcinfo.Mode(), modTime: srcinfo.ModTime()})
+	}
+	if err = me.plan(".", srcinfo, dstinfo); err != nil || me.DryRun {
+		return me.ops, err
+	}
+	return me.run()
+}
+
+// The state of one `Sync` call.
+type syncer struct {
+	*SyncOptions
+	src, dst Fs
+	srcRoot  string
+	dstRoot  string
+	ops      []SyncOp
+	dirs     []SyncOp               // `SyncTouch`es to restore directory modification times after all `ops`, deepest first
+	writable map[string]os.FileMode // existing read-only destination directories that `ops` write into, to be made owner-writable till their `SyncTouch`
+}
+
+// Appends to `me.ops` what needs doing to sync the directory `rel` (described by `srcinfo`)
+// into its destination (described by `dstinfo`, if that exists), recursively.
+func (me *syncer) plan(rel string, srcinfo os.FileInfo, dstinfo os.FileInfo) (err error) {
+	srcdirpath, dstdirpath, numops := filepath.Join(me.srcRoot, rel), filepath.Join(me.dstRoot, rel), len(me.ops)
+	var srcentries, dstentries []os.DirEntry
+	if srcentries, err = me.src.ReadDir(srcdirpath); err != nil {
+		return
+	}
+	dstinfos, srcnames := map[string]os.FileInfo{}, make(map[string]bool, len(srcentries))
+	if dstinfo != nil {
+		if dstentries, err = me.dst.ReadDir(dstdirpath); err != nil {
+			return
+		}
+		for _, entry := range dstentries {
+			if dstinfos[entry.Name()], err = entry.Info(); err != nil {
+				return
+			}
+		}
+	}
+	for _, entry := range srcentries {
+		srcnames[entry.Name()] = true
+	}
+	if me.DeleteExtraneous {
+		for _, entry := range dstentries {
+			if name := entry.Name(); !(srcnames[name] || me.ignored(filepath.Join(srcdirpath, name), entry.IsDir())) {
+				me.ops = append(me.ops, SyncOp{Action: SyncDelete, Path: filepath.Join(rel, name)})
+			}
+		}
+	}
+
+	for _, entry := range srcentries {
+		srcpath, subrel := filepath.Join(srcdirpath, entry.Name()), filepath.Join(rel, entry.Name())
+		if me.ignored(srcpath, entry.IsDir()) || (me.src == me.dst && srcpath == me.dstRoot) {
+			continue
+		}
+		var info os.FileInfo
+		if info, err = entry.Info(); err != nil {
+			return
+		}
+		dinfo := dstinfos[entry.Name()]
+		op := SyncOp{Path: subrel, mode: info.Mode(), modTime: info.ModTime()}
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			if dinfo != nil && !dinfo.IsDir() {
+				me.ops, dinfo = append(me.ops, SyncOp{Action: SyncDelete, Path: subrel}), nil
+			}
+			if dinfo == nil {
+				op.Action = SyncMkdir
+				me.ops = append(me.ops, op)
+			}
+			if err = me.plan(subrel, info, dinfo); err != nil {
+				return
+			}
+		case mode&os.ModeSymlink != 0:
+			if op.link, err = me.src.Readlink(srcpath); err != nil {
+				return
+			} else if dinfo != nil && dinfo.Mode()&os.ModeSymlink != 0 {
+				if link, _ := me.dst.Readlink(filepath.Join(dstdirpath, entry.Name())); link == op.link {
+					continue
+				}
+			} else if dinfo != nil && dinfo.IsDir() {
+				me.ops = append(me.ops, SyncOp{Action: SyncDelete, Path: subrel})
+			}
+			op.Action = SyncSymlink
+			me.ops = append(me.ops, op)
+		case mode.IsRegular():
+			op.Size = info.Size()
+			if dinfo != nil && dinfo.IsDir() {
+				me.ops, dinfo = append(me.ops, SyncOp{Action: SyncDelete, Path: subrel}), nil
+			}
+			if dinfo == nil {
+				op.Action = SyncCopy
+			} else if op.Action, err = me.compare(srcpath, info, filepath.Join(dstdirpath, entry.Name()), dinfo); err != nil {
+				return
+			}
+			if op.Action >= 0 {
+				me.ops = append(me.ops, op)
+			}
+		}
+	}
+
+	// restore the directory's attributes after its contents are done
+	touch, changed := SyncOp{Action: SyncTouch, Path: rel, mode: srcinfo.Mode(), modTime: srcinfo.ModTime()}, false
+	for _, op := range me.ops[numops:] {
+		if changed = filepath.Dir(op.Path) == rel; changed {
+			break
+		}
+	}
+	if changed && dstinfo != nil && dstinfo.Mode().Perm()&0300 != 0300 {
+		me.writable[rel] = dstinfo.Mode().Perm() | 0700
+	}
+	if dstinfo != nil && !me.attrsEqual(srcinfo, dstinfo) {
+		me.ops = append(me.ops, touch)
+	} else if dstinfo == nil || changed {
+		me.dirs = append(me.dirs, touch)
+	}
+	return
+}
+
+func (me *syncer) ignored(srcPath string, isDir bool) bool {
+	return me.Ignore != nil && me.Ignore.IsIgnored(srcPath, isDir)
+}
+
+func (me *syncer) attrsEqual(srcinfo os.FileInfo, dstinfo os.FileInfo) bool {
+	return srcinfo.Mode().Perm() == dstinfo.Mode().Perm() && me.timesEqual(srcinfo, dstinfo)
+}
+
+func (me *syncer) timesEqual(srcinfo os.FileInfo, dstinfo os.FileInfo) bool {
+	diff := srcinfo.ModTime().Sub(dstinfo.ModTime())
+	return diff <= me.ModTimeWindow && diff >= -me.ModTimeWindow
+}
+
+// Returns the `SyncAction` needed for the existing destination file, or `-1` if it's up to date.
+func (me *syncer) compare(srcFilePath string, srcinfo os.FileInfo, dstFilePath string, dstinfo os.FileInfo) (action SyncAction, err error) {
+	if me.Link == SyncLinkHard {
+		if os.SameFile(srcinfo, dstinfo) {
+			return -1, nil
+		}
+		return SyncUpdate, nil
+	} else if !dstinfo.Mode().IsRegular() || srcinfo.Size() != dstinfo.Size() {
+		return SyncUpdate, nil
+	} else if me.HashContents {
+		var srchash, dsthash string
+		if srchash, err = fileStatesHash(me.src, srcFilePath); err == nil {
+			dsthash, err = fileStatesHash(me.dst, dstFilePath)
+		}
+		if err != nil || srchash != dsthash {
+			return SyncUpdate, err
+		}
+	}
+	if me.attrsEqual(srcinfo, dstinfo) {
+		return -1, nil
+	} else if me.HashContents || me.timesEqual(srcinfo, dstinfo) {
+		return SyncTouch, nil
+	}
+	return SyncUpdate, nil
+}
+
+// Performs `me.ops`, then restores the modification times of the directories they changed.
+func (me *syncer) run() (done []SyncOp, err error) {
+	for rel, perm := range me.writable {
+		if err = me.dst.Chmod(filepath.Join(me.dstRoot, rel), perm); err != nil {
+			return nil, err
+		}
+	}
+	var progress SyncProgress
+	progress.OpsTotal = len(me.ops)
+	for _, op := range me.ops {
+		progress.BytesTotal += op.Size
+	}
+	for i := range me.ops {
+		op := &me.ops[i]
+		if err = me.do(op); err != nil {
+			return me.ops[:i], err
+		}
+		progress.OpsDone, progress.BytesDone = progress.OpsDone+1, progress.BytesDone+op.Size
+		if me.OnProgress != nil {
+			me.OnProgress(op, progress)
+		}
+	}
+	for i := range me.dirs {
+		if err = me.do(&me.dirs[i]); err != nil {
+			break
+		}
+	}
+	return me.ops, err
+}
+
+func (me *syncer) do(op *SyncOp) (err error) {
+	dstpath := filepath.Join(me.dstRoot, op.Path)
+	switch op.Action {
+	case SyncDelete:
+		err = me.dst.RemoveAll(dstpath)
+	case SyncMkdir: // owner-writable for its contents, till its deferred `SyncTouch`
+		if err = me.dst.MkdirAll(dstpath, op.mode.Perm()|0700); err == nil {
+			err = me.dst.Chmod(dstpath, op.mode.Perm()|0700) // regardless of umask
+		}
+	case SyncTouch:
+		if err = me.dst.Chmod(dstpath, op.mode.Perm()); err == nil {
+			err = me.dst.Chtimes(dstpath, op.modTime, op.modTime)
+		}
+	case SyncSymlink:
+		if _, errstat := me.dst.Lstat(dstpath); errstat == nil {
+			err = me.dst.Remove(dstpath)
+		}
+		if err == nil {
+			err = me.dst.Symlink(op.link, dstpath)
+		}
+	case SyncCopy, SyncUpdate:
+		err = me.copy(filepath.Join(me.srcRoot, op.Path), dstpath, op)
+	}
+	return
+}
+
+func (me *syncer) copy(srcFilePath string, dstFilePath string, op *SyncOp) (err error) {
+	if me.Link == SyncLinkHard {
+		if _, errstat := os.Lstat(dstFilePath); errstat == nil {
+			if err = os.Remove(dstFilePath); err != nil {
+				return
+			}
+		}
+		if os.Link(srcFilePath, dstFilePath) == nil {
+			return
+		}
+	}
+
+	tmppath := filepath.Join(filepath.Dir(dstFilePath), "."+filepath.Base(dstFilePath)+".ufs-sync")
+	if me.Link != SyncLinkReflink || reflinkFile(srcFilePath, tmppath, op.mode.Perm()) != nil {
+		var src, dst FsFile
+		if src, err = me.src.Open(srcFilePath); err != nil {
+			return
+		}
+		defer src.Close()
+		if dst, err = me.dst.OpenFile(tmppath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, op.mode.Perm()); err != nil {
+			return
+		}
+		_, err = io.Copy(dst, src)
+		if errclose := dst.Close(); err == nil {
+			err = errclose
+		}
+	}
+	if err == nil {
+		if err = me.dst.Chmod(tmppath, op.mode.Perm()); err == nil {
+			if err = me.dst.Chtimes(tmppath, op.modTime, op.modTime); err == nil {
+				err = me.dst.Rename(tmppath, dstFilePath)
+			}
+		}
+	}
+	if err != nil {
+		_ = me.dst.Remove(tmppath)
+	}
+	return
+}
@@ -0,0 +1,282 @@
+package ufs
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/metaleap/go-util"
+)
+
+//	A file system that can be read and written, in the manner of `io/fs.FS` but with the methods of package `os`
+//	(and its OS-style paths). Implemented by `OsFs`, `MemFs`, `OverlayFs` and `ZipFs`. Use `On` to run this package's
+//	functions on one, set it as `DirWalker.Fs` or `Ignorer.Fs`, or turn it into an `io/fs.FS` via `IoFs`.
+//	(`Watcher`, `FileStates`, `LockFile`, the archive and the `*Atomic` functions always use the OS file system.)
+type Fs interface {
+	//	Like `os.Open`.
+	Open(name string) (FsFile, error)
+
+	//	Like `os.OpenFile`.
+	OpenFile(name string, flag int, perm os.FileMode) (FsFile, error)
+
+	//	Like `os.Stat`.
+	Stat(name string) (os.FileInfo, error)
+
+	//	Like `os.Lstat`.
+	Lstat(name string) (os.FileInfo, error)
+
+	//	Like `os.ReadDir`: sorted by name.
+	ReadDir(name string) ([]os.DirEntry, error)
+
+	//	Like `os.Mkdir`.
+	Mkdir(name string, perm os.FileMode) error
+
+	//	Like `os.MkdirAll`.
+	MkdirAll(name string, perm os.FileMode) error
+
+	//	Like `os.Remove`.
+	Remove(name string) error
+
+	//	Like `os.RemoveAll`.
+	RemoveAll(name string) error
+
+	//	Like `os.Rename`.
+	Rename(oldName string, newName string) error
+
+	//	Like `os.Chmod`.
+	Chmod(name string, mode os.FileMode) error
+
+	//	Like `os.Chtimes`.
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+
+	//	Like `os.Symlink`.
+	Symlink(oldName string, newName string) error
+
+	//	Like `os.Readlink`.
+	Readlink(name string) (string, error)
+}
+
+//	An open file in an `Fs`, such as an `*os.File`.
+type FsFile interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Seeker
+	io.Closer
+	Name() string
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+}
+
+//	Returned (wrapped in an `*os.PathError`) by the writing methods of read-only `Fs`s, such as `ZipFs`.
+var ErrFsReadOnly = umisc.E("read-only file system")
+
+//	The `Fs` of the operating system, via package `os`. Used wherever an `Fs` is `nil`.
+var OsFs Fs = osFs{}
+
+type osFs struct{}
+
+func (osFs) Open(name string) (FsFile, error) {
+	return osFsFile(os.Open(name))
+}
+
+func (osFs) OpenFile(name string, flag int, perm os.FileMode) (FsFile, error) {
+	return osFsFile(os.OpenFile(name, flag, perm))
+}
+
+//	Avoids returning a non-`nil` `FsFile` holding a `nil` `*os.File`.
+func osFsFile(file *os.File, err error) (FsFile, error) {
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (osFs) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFs) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (osFs) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (osFs) Mkdir(name string, perm os.FileMode) error {
+	return os.Mkdir(name, perm)
+}
+
+func (osFs) MkdirAll(name string, perm os.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+func (osFs) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFs) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
+func (osFs) Rename(oldName string, newName string) error {
+	return os.Rename(oldName, newName)
+}
+
+func (osFs) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (osFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (osFs) Symlink(oldName string, newName string) error {
+	return os.Symlink(oldName, newName)
+}
+
+func (osFs) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+//	Returns `fsys`, or `OsFs` if it's `nil`.
+func fsOrOs(fsys Fs) Fs {
+	if fsys == nil {
+		return OsFs
+	}
+	return fsys
+}
+
+//	Like `ioutil.ReadFile`, but in `fsys`.
+func fsReadFile(fsys Fs, name string) (data []byte, err error) {
+	if fsys == OsFs {
+		return ioutil.ReadFile(name)
+	}
+	var file FsFile
+	if file, err = fsys.Open(name); err == nil {
+		defer file.Close()
+		data, err = ioutil.ReadAll(file)
+	}
+	return
+}
+
+//	Like `ioutil.WriteFile`, but in `fsys`.
+func fsWriteFile(fsys Fs, name string, data []byte, perm os.FileMode) (err error) {
+	var file FsFile
+	if file, err = fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm); err == nil {
+		_, err = file.Write(data)
+		if errclose := file.Close(); err == nil {
+			err = errclose
+		}
+	}
+	return
+}
+
+//	Like `os.SameFile`, but also for the `os.FileInfo`s of other `Fs`s (whose `Sys` then identifies the file).
+func fsSameFile(fi1 os.FileInfo, fi2 os.FileInfo) bool {
+	if os.SameFile(fi1, fi2) {
+		return true
+	}
+	sys1, ok1 := fi1.Sys().(*memNode)
+	sys2, ok2 := fi2.Sys().(*memNode)
+	return ok1 && ok2 && sys1 == sys2
+}
+
+//	Returns an `io/fs.FS` (also implementing `io/fs.ReadDirFS` and `io/fs.StatFS`) for the contents of
+//	`dirPath` in `fsys` (or `OsFs` if `nil`), such as for `io/fs.WalkDir`, `template.ParseFS` or `http.FS`.
+func IoFs(fsys Fs, dirPath string) fs.FS {
+	return &ioFs{fsys: fsOrOs(fsys), dirPath: dirPath}
+}
+
+type ioFs struct {
+	fsys    Fs
+	dirPath string
+}
+
+func (me *ioFs) path(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &os.PathError{Op: op, Path: name, Err: os.ErrInvalid}
+	}
+	return filepath.Join(me.dirPath, filepath.FromSlash(name)), nil
+}
+
+func (me *ioFs) Open(name string) (fs.File, error) {
+	fullpath, err := me.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := me.fsys.Open(fullpath)
+	if err != nil {
+		return nil, err
+	}
+	return &ioFsFile{FsFile: file, fsys: me.fsys, path: fullpath}, nil
+}
+
+func (me *ioFs) ReadDir(name string) ([]fs.DirEntry, error) {
+	fullpath, err := me.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return me.fsys.ReadDir(fullpath)
+}
+
+func (me *ioFs) Stat(name string) (fs.FileInfo, error) {
+	fullpath, err := me.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return me.fsys.Stat(fullpath)
+}
+
+//	An `FsFile` implementing `io/fs.ReadDirFile`.
+type ioFsFile struct {
+	FsFile
+	fsys    Fs
+	path    string
+	entries []os.DirEntry
+	listed  bool
+}
+
+func (me *ioFsFile) ReadDir(n int) (entries []fs.DirEntry, err error) {
+	if !me.listed {
+		if me.entries, err = me.fsys.ReadDir(me.path); err != nil {
+			return
+		}
+		me.listed = true
+	}
+	if n <= 0 || n >= len(me.entries) {
+		entries, me.entries = me.entries, nil
+		if n > 0 && len(entries) == 0 {
+			err = io.EOF
+		}
+		return
+	}
+	entries, me.entries = me.entries[:n], me.entries[n:]
+	return
+}
+
+//	An `os.FileInfo` under another name, such as that of the symbolic link it was `Stat`ed via.
+type fsNamedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (me *fsNamedInfo) Name() string {
+	return me.name
+}
+
+//	Returns `infos` as `os.DirEntry`s, sorted by name.
+func fsDirEntries(infos []os.FileInfo) (entries []os.DirEntry) {
+	entries = make([]os.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	sort.Slice(entries, func(i int, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return
+}
@@ -1,20 +1,34 @@
 package ufs
 
 import (
-	"io/ioutil"
+	"context"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
+
+	"github.com/metaleap/go-util"
 )
 
 //	Used for `DirWalker.DirVisitor` and `DirWalker.FileVisitor`.
 //	Always return `keepWalking` as true unless you want to immediately terminate a `Walk` early.
 type WalkerVisitor func(fullPath string) (keepWalking bool)
 
+//	Used for `DirWalker.DirEntryVisitor` and `DirWalker.FileEntryVisitor`: like a `WalkerVisitor`, but also
+//	receives the `entry` from the directory listing, so that its `Type` or `Info` doesn't need another `os.Stat`.
+//	For a symbolic link followed as per `DirWalker.FollowSymlinks`, `entry` describes the link target
+//	(but with the link's name). For the starting directory, `entry` is `nil` if it can't be `os.Stat`ed.
+type WalkerEntryVisitor func(fullPath string, entry os.DirEntry) (keepWalking bool)
+
 //	An empty `WalkerVisitor` used in place of a `nil` directory or file visitor during a `DirWalker.Walk`. Always returns `true`.
 func walkerVisitorNoop(_ string) bool {
 	return true
 }
 
+//	Reported (wrapped in an `*os.PathError`) by `DirWalker.Walk` for each directory not walked into because,
+//	via `DirWalker.FollowSymlinks`, it's the same as one of its own ancestor directories.
+var ErrWalkerSymlinkLoop = umisc.E("symbolic link loop")
+
 //	Provides recursive directory walking with a variety of options.
 type DirWalker struct {
 	//	`Walk` returns a slice of all `error`s encountered but keeps walking as indicated by
@@ -39,6 +53,32 @@ type DirWalker struct {
 
 	//	Called for every file being visited during a `Walk`.
 	FileVisitor WalkerVisitor
+
+	//	If set, called for every directory being visited during a `Walk`, instead of `DirVisitor`.
+	DirEntryVisitor WalkerEntryVisitor
+
+	//	If set, called for every file being visited during a `Walk`, instead of `FileVisitor`.
+	FileEntryVisitor WalkerEntryVisitor
+
+	//	If set, the files and directories it ignores are neither visited nor walked into.
+	Ignore *Ignorer
+
+	//	If `true`, symbolic links are visited as what they point to: links to directories as directories
+	//	(and walked into, unless that would loop, see `ErrWalkerSymlinkLoop`). Dangling links remain files.
+	//	If `false`, all symbolic links are visited as files.
+	FollowSymlinks bool
+
+	//	If greater than 1, the number of directories read concurrently during a `Walk`. Unless `Sorted`,
+	//	the visitors then get called concurrently from as many go-routines, and in no particular order
+	//	(other than each directory being visited before its contents, and `VisitDirsFirst` holding per directory).
+	Workers int
+
+	//	If `true`, a `Walk` with `Workers` still calls the visitors one after another in the same order as
+	//	without `Workers` (by sorted names, depth-first): only the reading of directories happens concurrently.
+	Sorted bool
+
+	//	The file system walked. If `nil`, `OsFs`.
+	Fs Fs
 }
 
 //	Initializes and returns a new `DirWalker` with the specified (optional) `WalkerVisitor`s.
@@ -50,55 +90,275 @@ func NewDirWalker(deep bool, dirVisitor, fileVisitor WalkerVisitor) (me *DirWalk
 
 //	Initiates a walk starting at the specified `dirPath`.
 func (me *DirWalker) Walk(dirPath string) (errs []error) {
-	me.walk(me.VisitSelf, dirPath, &errs)
-	return
+	return me.WalkContext(context.Background(), dirPath)
 }
 
-func (me *DirWalker) walk(walkSelf bool, dirPath string, errs *[]error) {
-	dirVisitor, fileVisitor := me.DirVisitor, me.FileVisitor
-	if dirVisitor == nil {
-		dirVisitor = walkerVisitorNoop
+//	Like `Walk`, but returns all `error`s encountered as a single `error` (as per `umisc.ErrsOf`).
+func (me *DirWalker) WalkErr(dirPath string) error {
+	return umisc.ErrsOf(me.Walk(dirPath))
+}
+
+//	Like `Walk`, but stops early once `ctx` is done, then including `ctx.Err()` in `errs`.
+func (me *DirWalker) WalkContext(ctx context.Context, dirPath string) (errs []error) {
+	walk := &dirWalk{DirWalker: me}
+	walk.ctx, walk.cancel = context.WithCancel(ctx)
+	defer walk.cancel()
+	if me.Workers > 1 {
+		walk.sem = make(chan struct{}, me.Workers)
+	}
+
+	var root os.DirEntry
+	var ancestors *walkerAncestor
+	if me.FollowSymlinks || me.DirEntryVisitor != nil {
+		if info, err := fsOrOs(me.Fs).Stat(dirPath); err == nil {
+			root, ancestors = fs.FileInfoToDirEntry(info), &walkerAncestor{info: info}
+		}
 	}
-	if fileVisitor == nil {
-		fileVisitor = walkerVisitorNoop
+	if !me.VisitSelf || walk.visit(true, dirPath, root) {
+		if walk.sem == nil || me.Sorted {
+			walk.walkSorted(dirPath, ancestors, walk.list(dirPath, false))
+		} else {
+			walk.pending.Add(1)
+			walk.walkParallel(dirPath, ancestors)
+			walk.pending.Wait()
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		walk.errs = append(walk.errs, err)
 	}
-	if walkSelf {
-		walkSelf = dirVisitor(dirPath)
+	return walk.errs
+}
+
+//	The state of one `DirWalker.WalkContext` call.
+type dirWalk struct {
+	*DirWalker
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mutex   sync.Mutex
+	errs    []error
+	sem     chan struct{}
+	pending sync.WaitGroup
+}
+
+//	The chain of directories from the starting directory down to the one being walked, for detecting symbolic link loops.
+type walkerAncestor struct {
+	info   os.FileInfo
+	parent *walkerAncestor
+}
+
+//	The (eventual) contents of a directory, as read by `dirWalk.list`.
+type walkerListing struct {
+	done  chan struct{}
+	dirs  []os.DirEntry
+	files []os.DirEntry
+	err   error
+}
+
+//	Records `err`, and returns whether to keep walking.
+func (me *dirWalk) fail(err error) (keepWalking bool) {
+	me.mutex.Lock()
+	me.errs = append(me.errs, err)
+	me.mutex.Unlock()
+	if me.BreakOnError {
+		me.cancel()
+	}
+	return !me.BreakOnError
+}
+
+func (me *dirWalk) stopped() bool {
+	return me.ctx.Err() != nil
+}
+
+//	Calls the visitor, and stops the whole walk if it says so.
+func (me *dirWalk) visit(isDir bool, fullPath string, entry os.DirEntry) (keepWalking bool) {
+	if me.stopped() {
+		return false
+	}
+	entryVisitor, visitor := me.FileEntryVisitor, me.FileVisitor
+	if isDir {
+		entryVisitor, visitor = me.DirEntryVisitor, me.DirVisitor
+	}
+	if entryVisitor != nil {
+		keepWalking = entryVisitor(fullPath, entry)
+	} else if visitor != nil {
+		keepWalking = visitor(fullPath)
 	} else {
-		walkSelf = true
+		keepWalking = walkerVisitorNoop(fullPath)
+	}
+	if !keepWalking {
+		me.cancel()
 	}
-	if walkSelf {
-		if fileInfos, err := ioutil.ReadDir(dirPath); err == nil {
-			if me.VisitDirsFirst {
-				if !me.walkInfos(dirPath, fileInfos, true, dirVisitor, errs) {
-					return
+	return
+}
+
+//	Returns the `walkerListing` of `dirPath`, read right away unless `async`, or else in
+//	the background (once one of the `Workers` is free). Its `done` gets closed when complete.
+func (me *dirWalk) list(dirPath string, async bool) (listing *walkerListing) {
+	listing = &walkerListing{done: make(chan struct{})}
+	read := func() {
+		defer close(listing.done)
+		if me.stopped() {
+			return
+		}
+		fsys := fsOrOs(me.Fs)
+		entries, err := fsys.ReadDir(dirPath)
+		if listing.err = err; err != nil {
+			return
+		}
+		var absDirPath string
+		if me.Ignore != nil {
+			absDirPath = me.Ignore.abs(dirPath)
+		}
+		for _, entry := range entries {
+			if me.FollowSymlinks && entry.Type()&os.ModeSymlink != 0 {
+				if info, err := fsys.Stat(filepath.Join(dirPath, entry.Name())); err == nil {
+					entry = fs.FileInfoToDirEntry(info)
+				}
+			}
+			if isDir := entry.IsDir(); me.Ignore == nil || !me.Ignore.ignores(filepath.Join(absDirPath, entry.Name()), isDir) {
+				if isDir {
+					listing.dirs = append(listing.dirs, entry)
+				} else {
+					listing.files = append(listing.files, entry)
 				}
 			}
-			if !me.walkInfos(dirPath, fileInfos, false, fileVisitor, errs) {
-				return
+		}
+	}
+	if !async {
+		read()
+	} else {
+		go func() {
+			if me.acquire() {
+				defer me.release()
 			}
-			if !me.VisitDirsFirst {
-				if !me.walkInfos(dirPath, fileInfos, true, dirVisitor, errs) {
-					return
+			read()
+		}()
+	}
+	return
+}
+
+//	Blocks until one of the `Workers` is free, or the walk stopped (then returning `false`).
+func (me *dirWalk) acquire() bool {
+	select {
+	case me.sem <- struct{}{}:
+		return true
+	case <-me.ctx.Done():
+		return false
+	}
+}
+
+func (me *dirWalk) release() {
+	<-me.sem
+}
+
+//	Returns the `walkerAncestor`s for walking into `entry` (at `fullPath`), or an `ErrWalkerSymlinkLoop` if it's one of them.
+func (me *dirWalk) descend(fullPath string, entry os.DirEntry, ancestors *walkerAncestor) (*walkerAncestor, error) {
+	if !me.FollowSymlinks {
+		return nil, nil
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return nil, err
+	}
+	for dir := ancestors; dir != nil; dir = dir.parent {
+		if fsSameFile(dir.info, info) {
+			return nil, &os.PathError{Op: "walk", Path: fullPath, Err: ErrWalkerSymlinkLoop}
+		}
+	}
+	return &walkerAncestor{info: info, parent: ancestors}, nil
+}
+
+//	Visits `listing` (of `dirPath`) and walks into its sub-directories, depth-first in order. With `Workers`,
+//	the listings of the sub-directories are requested ahead of visiting them, so they're read concurrently.
+func (me *dirWalk) walkSorted(dirPath string, ancestors *walkerAncestor, listing *walkerListing) (keepWalking bool) {
+	if <-listing.done; listing.err != nil {
+		return !me.stopped() && me.fail(listing.err)
+	}
+	var sublistings []*walkerListing
+	var subancestors []*walkerAncestor
+	if me.VisitSubDirs {
+		sublistings, subancestors = make([]*walkerListing, len(listing.dirs)), make([]*walkerAncestor, len(listing.dirs))
+		for i, entry := range listing.dirs {
+			fullPath := filepath.Join(dirPath, entry.Name())
+			if sub, err := me.descend(fullPath, entry, ancestors); err != nil {
+				if !me.fail(err) {
+					return false
 				}
+			} else if subancestors[i] = sub; me.sem != nil {
+				sublistings[i] = me.list(fullPath, true)
 			}
-		} else if *errs = append(*errs, err); me.BreakOnError {
-			return
 		}
 	}
+	visitDirs := func() bool {
+		for i, entry := range listing.dirs {
+			fullPath := filepath.Join(dirPath, entry.Name())
+			if !me.visit(true, fullPath, entry) {
+				return false
+			} else if me.VisitSubDirs && (subancestors[i] != nil || !me.FollowSymlinks) {
+				sublisting := sublistings[i]
+				if sublisting == nil {
+					sublisting = me.list(fullPath, false)
+				}
+				if !me.walkSorted(fullPath, subancestors[i], sublisting) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	if me.VisitDirsFirst && !visitDirs() {
+		return false
+	}
+	for _, entry := range listing.files {
+		if !me.visit(false, filepath.Join(dirPath, entry.Name()), entry) {
+			return false
+		}
+	}
+	return me.VisitDirsFirst || visitDirs()
 }
 
-func (me *DirWalker) walkInfos(dirPath string, fileInfos []os.FileInfo, isDir bool, visitor WalkerVisitor, errs *[]error) (keepWalking bool) {
-	var fullPath string
-	keepWalking = true
-	for _, fi := range fileInfos {
-		if fullPath = filepath.Join(dirPath, fi.Name()); fi.IsDir() == isDir {
-			if keepWalking = visitor(fullPath); !keepWalking {
-				break
-			} else if isDir && me.VisitSubDirs {
-				me.walk(false, fullPath, errs)
+//	Once one of the `Workers` is free, reads and visits the contents of `dirPath` and walks into its
+//	sub-directories, each in a new go-routine. Calls `me.pending.Done` when done.
+func (me *dirWalk) walkParallel(dirPath string, ancestors *walkerAncestor) {
+	defer me.pending.Done()
+	if !me.acquire() {
+		return
+	}
+	defer me.release()
+	listing := me.list(dirPath, false)
+	if listing.err != nil {
+		if !me.stopped() {
+			me.fail(listing.err)
+		}
+		return
+	}
+	visitDirs := func() bool {
+		for _, entry := range listing.dirs {
+			fullPath := filepath.Join(dirPath, entry.Name())
+			if !me.visit(true, fullPath, entry) {
+				return false
+			} else if me.VisitSubDirs {
+				if sub, err := me.descend(fullPath, entry, ancestors); err != nil {
+					if !me.fail(err) {
+						return false
+					}
+				} else {
+					me.pending.Add(1)
+					go me.walkParallel(fullPath, sub)
+				}
 			}
 		}
+		return true
+	}
+	if me.VisitDirsFirst && !visitDirs() {
+		return
+	}
+	for _, entry := range listing.files {
+		if !me.visit(false, filepath.Join(dirPath, entry.Name()), entry) {
+			return
+		}
+	}
+	if !me.VisitDirsFirst {
+		visitDirs()
 	}
-	return
 }
@@ -0,0 +1,31 @@
+// +build linux,!appengine
+
+package ufs
+
+import (
+	"syscall"
+)
+
+//	Returns whether `dirPath` is on a network or FUSE file-system, for which `inotify` only
+//	reports changes made locally (and thus `WatchBackendAuto` polls instead).
+func watchNeedsPolling(dirPath string) bool {
+	var st syscall.Statfs_t
+	if syscall.Statfs(dirPath, &st) != nil {
+		return false
+	}
+	switch uint32(st.Type) {
+	case 0x6969, // NFS
+		0x517B,     // SMB
+		0xFF534D42, // CIFS
+		0xFE534D42, // SMB2
+		0x65735546, // FUSE
+		0x01021997, // 9P
+		0x73757245, // CODA
+		0x5346414F, // AFS
+		0x00C36400, // CEPH
+		0x47504653, // GPFS
+		0x0BD00BD0: // LUSTRE
+		return true
+	}
+	return false
+}
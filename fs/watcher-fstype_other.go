@@ -0,0 +1,7 @@
+// +build !linux appengine
+
+package ufs
+
+func watchNeedsPolling(dirPath string) bool {
+	return false
+}
@@ -0,0 +1,150 @@
+package ufs
+
+import (
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type watchPollState struct {
+	size    int64
+	modTime int64
+	mode    os.FileMode
+	isDir   bool
+	hash    uint64
+}
+
+//	Polls all polled directories right away (rather than at the next `PollIntervalNano`), dispatching the resulting
+//	events before returning. If `Go` is running, this happens inside its loop, otherwise right here. Mostly useful for
+//	deterministic tests: with `Backend` set to `WatchBackendPoll`, every change made before `Poll` is seen by it.
+func (me *Watcher) Poll() {
+	me.mutex.Lock()
+	stopped := me.stopped
+	me.mutex.Unlock()
+	if stopped == nil {
+		me.poll()
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case me.pollNow <- done:
+		select {
+		case <-done:
+		case <-stopped:
+		}
+	case <-stopped:
+	}
+}
+
+func (me *Watcher) pollDue() <-chan time.Time {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if len(me.dirsPolled) == 0 {
+		return nil
+	} else if me.pollTimer == nil {
+		me.pollTimer = time.NewTimer(time.Duration(me.PollIntervalNano))
+	}
+	return me.pollTimer.C
+}
+
+//	Polls the deepest directories first, so that a removed directory's contents are reported before the directory itself.
+func (me *Watcher) poll() {
+	me.mutex.Lock()
+	dirs := make([]string, 0, len(me.dirsPolled))
+	for dir := range me.dirsPolled {
+		dirs = append(dirs, dir)
+	}
+	me.mutex.Unlock()
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		me.mutex.Lock()
+		var evts []WatchEvent
+		var err error
+		if _, polled := me.dirsPolled[dir]; polled {
+			evts, err = me.pollDir(dir, true)
+		}
+		me.mutex.Unlock()
+		if err != nil {
+			me.onError(err)
+		}
+		for _, evt := range evts {
+			me.onEvent(evt)
+		}
+	}
+}
+
+//	Compares `dirPath`'s current entries to those of its previous call, records them, and
+//	(if `report`) returns the changes as events. Must be called with `me.mutex` locked.
+func (me *Watcher) pollDir(dirPath string, report bool) (evts []WatchEvent, err error) {
+	states := map[string]watchPollState{}
+	fileInfos, err := ioutil.ReadDir(dirPath)
+	gone := err != nil && os.IsNotExist(err)
+	if err != nil && !(report && gone) {
+		return nil, err
+	}
+	err = nil
+	for _, fileInfo := range fileInfos {
+		state := watchPollState{size: fileInfo.Size(), modTime: fileInfo.ModTime().UnixNano(), mode: fileInfo.Mode(), isDir: fileInfo.IsDir()}
+		if me.PollHashes && fileInfo.Mode().IsRegular() {
+			state.hash = watchPollHash(filepath.Join(dirPath, fileInfo.Name()))
+		}
+		states[fileInfo.Name()] = state
+	}
+	prev := me.dirsPolled[dirPath]
+	me.dirsPolled[dirPath] = states
+	if !report {
+		return
+	}
+
+	var names []string
+	for name := range prev {
+		names = append(names, name)
+	}
+	for name := range states {
+		if _, existed := prev[name]; !existed {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(dirPath, name)
+		old, existed := prev[name]
+		cur, exists := states[name]
+		switch {
+		case !exists:
+			evts = append(evts, WatchEvent{Path: path, Op: WatchRemove, IsDir: old.isDir})
+		case !existed:
+			evts = append(evts, WatchEvent{Path: path, Op: WatchCreate, IsDir: cur.isDir})
+		case cur.isDir != old.isDir || cur.mode&os.ModeType != old.mode&os.ModeType:
+			evts = append(evts, WatchEvent{Path: path, Op: WatchRemove, IsDir: old.isDir}, WatchEvent{Path: path, Op: WatchCreate, IsDir: cur.isDir})
+		default:
+			var op WatchOp
+			if !cur.isDir && (cur.size != old.size || cur.modTime != old.modTime || cur.hash != old.hash) {
+				op |= WatchWrite
+			}
+			if cur.mode.Perm() != old.mode.Perm() {
+				op |= WatchChmod
+			}
+			if op != 0 {
+				evts = append(evts, WatchEvent{Path: path, Op: op, IsDir: cur.isDir})
+			}
+		}
+	}
+	if gone && !me.dirsWatching[filepath.Dir(dirPath)] { // otherwise, the parent directory reports the removal
+		evts = append(evts, WatchEvent{Path: dirPath, Op: WatchRemove, IsDir: true})
+	}
+	return
+}
+
+func watchPollHash(filePath string) uint64 {
+	hash := fnv.New64a()
+	if file, err := os.Open(filePath); err == nil {
+		defer file.Close()
+		_, _ = io.Copy(hash, file)
+	}
+	return hash.Sum64()
+}
@@ -0,0 +1,97 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package ufs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+//	Collects the events passed to its `on` handler, which may run on any go-routine.
+type testWatchEvents struct {
+	mutex sync.Mutex
+	evts  []WatchEvent
+}
+
+func (me *testWatchEvents) on(evt WatchEvent) {
+	me.mutex.Lock()
+	me.evts = append(me.evts, evt)
+	me.mutex.Unlock()
+}
+
+//	Returns and forgets all events collected so far.
+func (me *testWatchEvents) take() (evts []WatchEvent) {
+	me.mutex.Lock()
+	evts, me.evts = me.evts, nil
+	me.mutex.Unlock()
+	return
+}
+
+func expectWatchEvents(t *testing.T, what string, got []WatchEvent, want ...WatchEvent) {
+	t.Helper()
+	if !((len(got) == 0 && len(want) == 0) || reflect.DeepEqual(got, want)) {
+		t.Errorf("%s:\n\tgot  %v\n\twant %v", what, got, want)
+	}
+}
+
+func TestWatcherPoll(t *testing.T) {
+	dirPath, cleanup := testWatcherDir(t)
+	defer cleanup()
+	var rec testWatchEvents
+	w, _ := NewWatcher()
+	defer w.Close()
+	w.Backend, w.DebounceNano = WatchBackendPoll, 0
+	if errs := w.WatchTree(dirPath, "*", false, rec.on); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	file, sub := filepath.Join(dirPath, "file"), filepath.Join(dirPath, "sub")
+	subfile := filepath.Join(sub, "subfile")
+	write := func(filePath string, contents string) {
+		if err := ioutil.WriteFile(filePath, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w.Poll()
+	expectWatchEvents(t, "no changes", rec.take())
+
+	write(file, "1")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	w.Poll()
+	expectWatchEvents(t, "create", rec.take(), WatchEvent{Path: file, Op: WatchCreate}, WatchEvent{Path: sub, Op: WatchCreate, IsDir: true})
+
+	write(file, "22")
+	write(subfile, "1")
+	w.Poll()
+	expectWatchEvents(t, "modify, create in new sub-directory", rec.take(), WatchEvent{Path: subfile, Op: WatchCreate}, WatchEvent{Path: file, Op: WatchWrite})
+
+	write(subfile, "22")
+	if err := os.Chmod(file, 0600); err != nil {
+		t.Fatal(err)
+	}
+	w.Poll()
+	expectWatchEvents(t, "modify in sub-directory, chmod", rec.take(), WatchEvent{Path: subfile, Op: WatchWrite}, WatchEvent{Path: file, Op: WatchChmod})
+
+	if err := os.RemoveAll(sub); err != nil {
+		t.Fatal(err)
+	} else if err = os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	w.Poll()
+	expectWatchEvents(t, "remove", rec.take(), WatchEvent{Path: subfile, Op: WatchRemove}, WatchEvent{Path: file, Op: WatchRemove}, WatchEvent{Path: sub, Op: WatchRemove, IsDir: true})
+	w.mutex.Lock()
+	_, polled := w.dirsPolled[sub]
+	w.mutex.Unlock()
+	if polled {
+		t.Error("removed sub-directory still polled")
+	}
+
+	w.Poll()
+	expectWatchEvents(t, "no more changes", rec.take())
+}
@@ -3,35 +3,27 @@
 package ufs
 
 import (
-	"path/filepath"
-
-	"github.com/metaleap/go-util/str"
+	"errors"
 )
 
-//	A convenient wrapper around `go-forks/fsnotify.Watcher`.
-//
-//	**NOTE**: `godocdown` picked `watcher-sandboxed.go` shim instead of `watcher-default.go`:
-//	Refer to http://godoc.org/github.com/metaleap/go-util/fs#Watcher for *actual* docs on `Watcher`.
-type Watcher struct {
+//	No OS notification mechanism in the sandbox: `newWatchNotifier` always fails, so that `Watcher`s poll.
+type watchNotifier struct {
+	events chan WatchEvent
+	Error  chan error
 }
 
-//	Returns a new `Watcher`, `err` is always nil.
-func NewWatcher() (me *Watcher, err error) {
-	me = &Watcher{}
-	return
+func newWatchNotifier() (me *watchNotifier, err error) {
+	return nil, errors.New("file-system notifications are unavailable in this sandbox")
 }
 
-//	Closes the underlying `me.Watcher`.
-func (me *Watcher) Close() (err error) {
-	return
+func (me *watchNotifier) Watch(dirPath string) error {
+	return errors.New("file-system notifications are unavailable in this sandbox")
 }
 
-func (me *Watcher) Go() {
+func (me *watchNotifier) RemoveWatch(dirPath string) error {
+	return nil
 }
 
-func (me *Watcher) WatchIn(dirPath string, namePattern ustr.Pattern, runHandlerNow bool, handler WatcherHandler) (errs []error) {
-	if runHandlerNow {
-		errs = watchRunHandler(filepath.Clean(dirPath), namePattern, handler)
-	}
-	return
+func (me *watchNotifier) close() error {
+	return nil
 }
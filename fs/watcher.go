@@ -0,0 +1,559 @@
+package ufs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metaleap/go-util/log"
+	"github.com/metaleap/go-util/str"
+)
+
+//	The mechanisms by which a `Watcher` can observe directories.
+type WatchBackend int
+
+const (
+	//	Per directory, the OS notification mechanism (via `go-forks/fsnotify`) if available and working, otherwise
+	//	polling: that is, if it's unavailable in this build (`appengine`) or couldn't be initialized, if adding the
+	//	watch fails (such as when exceeding the `inotify` limits in containers), or for network and FUSE file-systems
+	//	on Linux (where `inotify` silently misses changes made by other machines).
+	WatchBackendAuto WatchBackend = iota
+
+	//	Always the OS notification mechanism (via `go-forks/fsnotify`), failing if it's unavailable.
+	WatchBackendNotify
+
+	//	Always polling: comparing the directories' entries every `PollIntervalNano` by their
+	//	sizes, modification times and permissions (and content hashes if `PollHashes`).
+	WatchBackendPoll
+)
+
+//	Watches directories for file-system changes, via the OS notification mechanism and / or by polling (see `Backend`).
+//
+//	Usage:
+//		w, err := ufs.NewWatcher()
+//		w.WatchIn(dir, pattern, runNow, handler)
+//		go w.Go()
+//		otherCode(laterOn...)
+//		w.WatchIn(anotherDir...)
+//		w.Close()
+type Watcher struct {
+	//	Defaults to a `time.Duration` of 250 milliseconds
+	DebounceNano int64
+
+	//	For `WatchBatched` handlers: events are collected until none arrived for this long,
+	//	then delivered. Defaults to a `time.Duration` of 100 milliseconds.
+	BatchQuietNano int64
+
+	//	For `WatchBatched` handlers: events are delivered at the latest this long after the first
+	//	one in the batch arrived, even if more keep coming. Defaults to a `time.Duration` of 1 second.
+	BatchMaxLatencyNano int64
+
+	//	If greater than 0, the number of go-routines that `Go` starts to run the `WatcherHandler`s and
+	//	`WatcherBatchHandler`s on, instead of running them one after another inside its event loop.
+	//	(Handlers might then run concurrently, and plain `WatcherHandler`s out of order.)
+	Workers int
+
+	//	How subsequent `WatchIn`, `WatchTree` and `WatchBatched` calls observe their directories.
+	//	Defaults to `WatchBackendAuto`.
+	Backend WatchBackend
+
+	//	The interval at which polled directories are compared. Defaults to a `time.Duration` of 1 second.
+	PollIntervalNano int64
+
+	//	If `true`, polling also compares the contents of files (by hash), catching changes
+	//	that keep both size and modification time (with coarse file-system clocks). Costly for big files.
+	PollHashes bool
+
+	//	A collection of custom `WatchEvent` handlers, called for all (non-ignored, debounced) events.
+	//	Not related to the handlers specified in your `Watcher.WatchIn` calls.
+	OnEvent []func(evt WatchEvent)
+
+	//	A collection of custom `error` handlers. If there are none, `error`s are reported to `Log` instead.
+	OnError []func(err error)
+
+	//	Defaults to `ufs.Log.Sub("Watcher")`.
+	Log *ulog.Logger
+
+	//	If set, events for the files and directories it ignores are dropped (and changes
+	//	to its ignore files make it re-read them). `WatchTree` doesn't watch ignored directories.
+	Ignore *Ignorer
+
+	notifier     *watchNotifier
+	notifierErr  error
+	ctx          context.Context
+	cancel       context.CancelFunc
+	closeOnce    sync.Once
+	closeErr     error
+	stopped      chan struct{}
+	mutex        sync.Mutex
+	dirsWatching map[string]bool
+	dirsGone     map[string]bool
+	dirsTrees    map[string]bool
+	dirsPolled   map[string]map[string]watchPollState
+	handlers     []*watchHandler
+	lastEvt      map[WatchEvent]int64
+	batch        []WatchEvent
+	batchSince   time.Time
+	batchTimer   *time.Timer
+	pollTimer    *time.Timer
+	pollNow      chan chan struct{}
+	wake         chan struct{}
+	jobs         chan func()
+}
+
+type watchHandler struct {
+	dirPath     string
+	namePattern ustr.Pattern
+	recursive   bool
+	on          WatcherHandler
+	onBatch     WatcherBatchHandler
+}
+
+func (me *watchHandler) matches(path string) bool {
+	dirPath := filepath.Dir(path)
+	return (dirPath == me.dirPath || (me.recursive && watchIsInDir(dirPath, me.dirPath))) && me.namePattern.IsMatch(filepath.Base(path))
+}
+
+//	Always returns a new `Watcher`. If the OS notification mechanism is unavailable, `err` says why,
+//	but `me` remains usable with its (default) `WatchBackendAuto` or `WatchBackendPoll` `Backend`.
+func NewWatcher() (me *Watcher, err error) {
+	return NewWatcherContext(context.Background())
+}
+
+//	Like `NewWatcher`, but once `ctx` is done, `Go` returns and `me` gets `Close`d.
+func NewWatcherContext(ctx context.Context) (me *Watcher, err error) {
+	me = &Watcher{dirsWatching: map[string]bool{}, dirsGone: map[string]bool{}, dirsTrees: map[string]bool{}, dirsPolled: map[string]map[string]watchPollState{},
+		lastEvt: map[WatchEvent]int64{}, pollNow: make(chan chan struct{}), wake: make(chan struct{}, 1)}
+	me.ctx, me.cancel = context.WithCancel(ctx)
+	me.DebounceNano = (250 * time.Millisecond).Nanoseconds()
+	me.BatchQuietNano, me.BatchMaxLatencyNano = (100 * time.Millisecond).Nanoseconds(), time.Second.Nanoseconds()
+	me.PollIntervalNano = time.Second.Nanoseconds()
+	me.Log = Log.Sub("Watcher")
+	if me.notifier, err = newWatchNotifier(); err != nil {
+		me.notifierErr = err
+		me.Log.Debug("polling only", "err", err)
+	}
+	return
+}
+
+//	Stops `Go` (waiting for it to return, which includes delivering a pending `WatchBatched` batch
+//	and waiting for the `Workers` to finish), then releases the OS notification resources.
+//	Safe to call multiple times and concurrently, and also if `Go` never ran. Must never be called synchronously
+//	from within any handler (with or without `Workers`), as it would wait for that very handler: use `go me.Close()` there.
+func (me *Watcher) Close() error {
+	me.closeOnce.Do(func() {
+		me.mutex.Lock()
+		me.cancel()
+		stopped := me.stopped
+		me.mutex.Unlock()
+		if stopped != nil {
+			<-stopped
+		}
+		if me.notifier != nil {
+			me.closeErr = me.notifier.close()
+		}
+	})
+	return me.closeErr
+}
+
+//	Starts watching. A loop designed to be called in a new go-routine, as in `go myWatcher.Go`. It blocks
+//	(without consuming any CPU) on file-system events or the next poll, and returns once `me.Close()` is called
+//	or the `context.Context` given to `NewWatcherContext` is done (in which case it `Close`s `me`).
+func (me *Watcher) Go() {
+	me.mutex.Lock()
+	if me.stopped != nil || me.ctx.Err() != nil {
+		me.mutex.Unlock()
+		return
+	}
+	stopped := make(chan struct{})
+	me.stopped = stopped
+	me.mutex.Unlock()
+	defer me.Close()
+	defer close(stopped)
+	defer me.Log.Debug("stopped")
+
+	var workers sync.WaitGroup
+	if me.Workers > 0 {
+		me.jobs = make(chan func(), me.Workers)
+		for i := 0; i < me.Workers; i++ {
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				for job := range me.jobs {
+					job()
+				}
+			}()
+		}
+		defer func() {
+			close(me.jobs)
+			workers.Wait()
+			me.jobs = nil
+		}()
+	}
+	defer func() {
+		if len(me.batch) > 0 {
+			me.batchTimer.Stop()
+			me.batchFlush()
+		}
+		if me.pollTimer != nil {
+			me.pollTimer.Stop()
+		}
+	}()
+
+	var events <-chan WatchEvent
+	var errs <-chan error
+	if me.notifier != nil {
+		events, errs = me.notifier.events, me.notifier.Error
+	}
+	for {
+		select {
+		case <-me.ctx.Done():
+			return
+		case evt, ok := <-events:
+			if !ok {
+				events = nil
+			} else {
+				me.onEvent(evt)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+			} else if err != nil {
+				me.onError(err)
+			}
+		case <-me.batchDue():
+			me.batchFlush()
+		case <-me.pollDue():
+			me.pollTimer = nil
+			me.poll()
+		case done := <-me.pollNow:
+			me.poll()
+			close(done)
+		case <-me.wake: // a directory to poll was added, so `pollDue` needs re-evaluating
+		}
+	}
+}
+
+func (me *Watcher) onError(err error) {
+	if len(me.OnError) == 0 {
+		me.Log.Warn(err.Error())
+	}
+	for _, onErr := range me.OnError {
+		onErr(err)
+	}
+}
+
+func (me *Watcher) onEvent(evt WatchEvent) {
+	if evt.Op&(WatchRemove|WatchRename) != 0 {
+		me.mutex.Lock() // a watched directory's removal is reported both by itself and by its parent directory
+		watching := me.dirsWatching[evt.Path]
+		evt.IsDir = evt.IsDir || watching || me.dirsGone[evt.Path]
+		me.mutex.Unlock()
+		if watching {
+			me.unwatchDirs(evt.Path)
+		}
+	} else if fileInfo, err := os.Stat(evt.Path); err == nil {
+		evt.IsDir = fileInfo.IsDir()
+	}
+	if me.ignores(evt.Path, evt.IsDir) {
+		return
+	}
+
+	var created []WatchEvent
+	if evt.IsDir && evt.Op.Has(WatchCreate) && me.inTree(evt.Path) {
+		// anything created inside the new directory before the watch got added would go unnoticed otherwise
+		var errs []error
+		created, errs = me.watchTree(evt.Path, true)
+		for _, err := range errs {
+			me.onError(err)
+		}
+	}
+	me.batchAdd(append([]WatchEvent{evt}, created...))
+	for _, evt = range append([]WatchEvent{evt}, created...) {
+		if me.dispatch(evt) {
+			for _, onEvt := range me.OnEvent {
+				onEvt(evt)
+			}
+		}
+	}
+}
+
+//	Runs `job` on one of the `Workers` if any, else right away.
+func (me *Watcher) run(job func()) {
+	if me.jobs != nil {
+		me.jobs <- job
+	} else {
+		job()
+	}
+}
+
+//	Calls the `WatcherHandler`s matching `evt.Path` unless an equal `evt` was dispatched less than `DebounceNano` ago.
+func (me *Watcher) dispatch(evt WatchEvent) bool {
+	now := time.Now().UnixNano()
+	if last, hasLast := me.lastEvt[evt]; hasLast && now-last <= me.DebounceNano {
+		return false
+	} else if len(me.lastEvt) >= 4096 {
+		for old, last := range me.lastEvt {
+			if now-last > me.DebounceNano {
+				delete(me.lastEvt, old)
+			}
+		}
+	}
+	me.lastEvt[evt] = now
+
+	var ons []WatcherHandler
+	me.mutex.Lock()
+	for _, handler := range me.handlers {
+		if handler.on != nil && handler.matches(evt.Path) {
+			ons = append(ons, handler.on)
+		}
+	}
+	me.mutex.Unlock()
+	for _, on := range ons {
+		on := on
+		me.run(func() { on(evt) })
+	}
+	return true
+}
+
+//	Adds `evts` to the current batch if there are any `WatchBatched` handlers, (re)scheduling its delivery.
+func (me *Watcher) batchAdd(evts []WatchEvent) {
+	me.mutex.Lock()
+	var hasbatchhandlers bool
+	for _, handler := range me.handlers {
+		hasbatchhandlers = hasbatchhandlers || handler.onBatch != nil
+	}
+	me.mutex.Unlock()
+	if !hasbatchhandlers {
+		return
+	}
+
+	now := time.Now()
+	if len(me.batch) == 0 {
+		me.batchSince = now
+	}
+	me.batch = append(me.batch, evts...)
+	due := time.Duration(me.BatchQuietNano)
+	if latest := me.batchSince.Add(time.Duration(me.BatchMaxLatencyNano)).Sub(now); latest < due {
+		due = latest
+	}
+	if me.batchTimer != nil {
+		me.batchTimer.Stop()
+	}
+	me.batchTimer = time.NewTimer(due)
+}
+
+//	Returns the channel signalling that the current batch is due for delivery, or `nil` if there's no batch.
+func (me *Watcher) batchDue() <-chan time.Time {
+	if me.batchTimer == nil {
+		return nil
+	}
+	return me.batchTimer.C
+}
+
+//	Delivers the current batch to all matching `WatchBatched` handlers.
+func (me *Watcher) batchFlush() {
+	batch := me.batch
+	me.batch, me.batchTimer = nil, nil
+	me.mutex.Lock()
+	handlers := append([]*watchHandler(nil), me.handlers...)
+	me.mutex.Unlock()
+	for _, handler := range handlers {
+		if handler.onBatch != nil {
+			if evts := watchBatchFor(handler, batch); len(evts) > 0 {
+				onbatch := handler.onBatch
+				me.run(func() { onbatch(evts) })
+			}
+		}
+	}
+}
+
+//	Returns the events in `batch` matching `handler`, one per path (in the order of their
+//	first occurrence) with all its `Op`s combined and the last-known `IsDir`.
+func watchBatchFor(handler *watchHandler, batch []WatchEvent) (evts []WatchEvent) {
+	idx := map[string]int{}
+	for _, evt := range batch {
+		if i, ok := idx[evt.Path]; ok {
+			evts[i].Op, evts[i].IsDir = evts[i].Op|evt.Op, evt.IsDir
+		} else if handler.matches(evt.Path) {
+			idx[evt.Path] = len(evts)
+			evts = append(evts, evt)
+		}
+	}
+	return
+}
+
+func (me *Watcher) ignores(path string, isDir bool) bool {
+	if me.Ignore == nil {
+		return false
+	}
+	if me.Ignore.IsIgnoreFile(filepath.Base(path)) {
+		me.Ignore.Reload(filepath.Dir(path))
+	}
+	return me.Ignore.IsIgnored(path, isDir)
+}
+
+//	Returns whether `path` is `dirPath` or inside it.
+func watchIsInDir(path string, dirPath string) bool {
+	if path == dirPath {
+		return true
+	} else if !strings.HasSuffix(dirPath, string(filepath.Separator)) {
+		dirPath += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dirPath)
+}
+
+//	Returns whether the directory at `dirPath` is inside a directory being watched via `WatchTree`.
+func (me *Watcher) inTree(dirPath string) bool {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	for root := range me.dirsTrees {
+		if watchIsInDir(dirPath, root) {
+			return true
+		}
+	}
+	return false
+}
+
+func (me *Watcher) watchDir(dirPath string) (err error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if me.dirsWatching[dirPath] {
+		return
+	}
+	poll := me.Backend == WatchBackendPoll || (me.Backend == WatchBackendAuto && (me.notifier == nil || watchNeedsPolling(dirPath)))
+	if !poll && me.notifier == nil {
+		return me.notifierErr
+	} else if !poll {
+		if err = me.notifier.Watch(dirPath); err != nil {
+			if me.Backend != WatchBackendAuto || os.IsNotExist(err) || os.IsPermission(err) {
+				return
+			}
+			me.Log.Debug("polling instead", "dir", dirPath, "err", err)
+			poll = true
+		}
+	}
+	if poll {
+		if _, err = me.pollDir(dirPath, false); err != nil {
+			return
+		}
+		select {
+		case me.wake <- struct{}{}:
+		default:
+		}
+	}
+	delete(me.dirsGone, dirPath)
+	me.Log.Debug("watching", "dir", dirPath, "poll", poll)
+	me.dirsWatching[dirPath] = true
+	return
+}
+
+//	Stops watching `dirPath` and all directories inside it, which must have been removed or renamed.
+func (me *Watcher) unwatchDirs(dirPath string) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if len(me.dirsGone) > 1024 { // only needed until the OS has reported the removal, so just to bound its growth
+		me.dirsGone = map[string]bool{}
+	}
+	for dir := range me.dirsWatching {
+		if watchIsInDir(dir, dirPath) {
+			if _, polled := me.dirsPolled[dir]; polled {
+				delete(me.dirsPolled, dir)
+			} else if me.notifier != nil {
+				_ = me.notifier.RemoveWatch(dir) // fails for already-gone directories, which the OS stopped watching anyway
+			}
+			delete(me.dirsWatching, dir)
+			me.dirsGone[dir] = true
+			delete(me.dirsTrees, dir)
+			me.Log.Debug("unwatching", "dir", dir)
+		}
+	}
+}
+
+//	Watches `dirPath` and all its (non-ignored) sub-directories. If `reportContents`, returns
+//	`WatchCreate` events for all the files and directories inside `dirPath`.
+func (me *Watcher) watchTree(dirPath string, reportContents bool) (created []WatchEvent, errs []error) {
+	walker := NewDirWalker(true, func(fullPath string) bool {
+		if err := me.watchDir(fullPath); err != nil {
+			errs = append(errs, err)
+			return false
+		} else if reportContents && fullPath != dirPath {
+			created = append(created, WatchEvent{Path: fullPath, Op: WatchCreate, IsDir: true})
+		}
+		return true
+	}, func(fullPath string) bool {
+		if reportContents {
+			created = append(created, WatchEvent{Path: fullPath, Op: WatchCreate})
+		}
+		return true
+	})
+	walker.Ignore = me.Ignore
+	errs = append(errs, walker.Walk(dirPath)...)
+	return
+}
+
+//	Watches dirs/files (whose `filepath.Base` names match the specified `namePattern`) inside the specified `dirPath` for change event notifications.
+//
+//	`handler` is invoked whenever a change event is observed, providing the full path and kind of change.
+//
+//	`runHandlerNow` allows immediate one-off invokation of `handler`. This will `DirWalker.Walk` the `dirPath`.
+//
+//	An empty `namePattern` is equivalent to `*`.
+func (me *Watcher) WatchIn(dirPath string, namePattern ustr.Pattern, runHandlerNow bool, handler WatcherHandler) (errs []error) {
+	dirPath = filepath.Clean(dirPath)
+	if err := me.watchDir(dirPath); err != nil {
+		errs = append(errs, err)
+	} else {
+		me.addHandler(&watchHandler{dirPath: dirPath, namePattern: namePattern, on: handler})
+		if runHandlerNow {
+			errs = append(errs, watchRunHandler(dirPath, namePattern, handler, me.Ignore, false)...)
+		}
+	}
+	return
+}
+
+//	Like `WatchIn`, but recursively: also watches all sub-directories of `dirPath` other than those
+//	ignored by `me.Ignore`, including those created later on (for whose already-existing contents
+//	`WatchCreate` events are then reported too). Removed or renamed directories are no longer watched.
+func (me *Watcher) WatchTree(dirPath string, namePattern ustr.Pattern, runHandlerNow bool, handler WatcherHandler) (errs []error) {
+	dirPath = filepath.Clean(dirPath)
+	if _, errs = me.watchTree(dirPath, false); len(errs) == 0 {
+		me.addHandler(&watchHandler{dirPath: dirPath, namePattern: namePattern, recursive: true, on: handler})
+		if runHandlerNow {
+			errs = append(errs, watchRunHandler(dirPath, namePattern, handler, me.Ignore, true)...)
+		}
+	}
+	return
+}
+
+//	Like `WatchIn` (or `WatchTree` if `recursive`), but `handler` receives batches of events: those occurring
+//	until none occurred for `BatchQuietNano` (or at the latest `BatchMaxLatencyNano` after the first one), then
+//	de-duplicated to one `WatchEvent` per path with all its `Op`s combined. `runHandlerNow` invokes it once
+//	with all existing matching files and directories (with an `Op` of `0`).
+func (me *Watcher) WatchBatched(dirPath string, namePattern ustr.Pattern, recursive bool, runHandlerNow bool, handler WatcherBatchHandler) (errs []error) {
+	dirPath = filepath.Clean(dirPath)
+	if recursive {
+		_, errs = me.watchTree(dirPath, false)
+	} else if err := me.watchDir(dirPath); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		me.addHandler(&watchHandler{dirPath: dirPath, namePattern: namePattern, recursive: recursive, onBatch: handler})
+		if runHandlerNow {
+			errs = append(errs, watchRunBatchHandler(dirPath, namePattern, handler, me.Ignore, recursive)...)
+		}
+	}
+	return
+}
+
+func (me *Watcher) addHandler(handler *watchHandler) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if handler.recursive {
+		me.dirsTrees[handler.dirPath] = true
+	}
+	me.handlers = append(me.handlers, handler)
+}
@@ -0,0 +1,326 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package ufs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func processCPUTime(t *testing.T) time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		t.Fatal(err)
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+func newTestWatcher(t *testing.T, dirPath string, backend WatchBackend, handler WatcherHandler) *Watcher {
+	w, _ := NewWatcher()
+	if w.Backend = backend; handler == nil {
+		handler = func(WatchEvent) {}
+	}
+	if errs := w.WatchIn(dirPath, "*", false, handler); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	return w
+}
+
+func testWatcherDir(t *testing.T) (dirPath string, cleanup func()) {
+	dirPath, err := ioutil.TempDir("", "ufs-watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dirPath, func() { os.RemoveAll(dirPath) }
+}
+
+//	Waits for `done` to be closed, failing `t` if that takes too long.
+func waitOrFail(t *testing.T, done <-chan struct{}, what string) {
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal(what + " hangs")
+	}
+}
+
+//	Fails `t` if the number of go-routines doesn't return to (at most) `before` soon.
+func checkNoLeakedGoroutines(t *testing.T, before int) {
+	for i := 0; i < 100; i++ {
+		if runtime.NumGoroutine() <= before {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	buf := make([]byte, 1<<16)
+	t.Fatalf("leaked %d go-routines:\n%s", runtime.NumGoroutine()-before, buf[:runtime.Stack(buf, true)])
+}
+
+func TestWatcherIdleCPU(t *testing.T) {
+	dirPath, cleanup := testWatcherDir(t)
+	defer cleanup()
+	for _, backend := range []WatchBackend{WatchBackendAuto, WatchBackendPoll} {
+		w := newTestWatcher(t, dirPath, backend, nil)
+		stopped := make(chan struct{})
+		go func() { w.Go(); close(stopped) }()
+		time.Sleep(100 * time.Millisecond)
+
+		cpubefore := processCPUTime(t)
+		time.Sleep(time.Second)
+		if cpu := processCPUTime(t) - cpubefore; cpu > 50*time.Millisecond {
+			t.Errorf("backend %d: %v of CPU time while idle for 1s", backend, cpu)
+		}
+		w.Close()
+		waitOrFail(t, stopped, "Go after Close")
+	}
+}
+
+func TestWatcherCloseWithoutGo(t *testing.T) {
+	dirPath, cleanup := testWatcherDir(t)
+	defer cleanup()
+	before := runtime.NumGoroutine()
+	w := newTestWatcher(t, dirPath, WatchBackendAuto, nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			w.Close()
+		}
+		w.Go() // must return right away once closed
+	}()
+	waitOrFail(t, done, "Close without Go")
+	checkNoLeakedGoroutines(t, before)
+}
+
+func TestWatcherCloseConcurrently(t *testing.T) {
+	dirPath, cleanup := testWatcherDir(t)
+	defer cleanup()
+	for _, workers := range []int{0, 4} {
+		before := runtime.NumGoroutine()
+		w := newTestWatcher(t, dirPath, WatchBackendAuto, nil)
+		w.Workers = workers
+		stopped := make(chan struct{})
+		go func() { w.Go(); close(stopped) }()
+		time.Sleep(50 * time.Millisecond)
+
+		var closers sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			closers.Add(1)
+			go func() { defer closers.Done(); w.Close() }()
+		}
+		done := make(chan struct{})
+		go func() { closers.Wait(); close(done) }()
+		waitOrFail(t, done, "concurrent Close")
+		waitOrFail(t, stopped, "Go after Close")
+		if err := w.Close(); err != nil { // once more, after `Go` returned
+			t.Error(err)
+		}
+		checkNoLeakedGoroutines(t, before)
+	}
+}
+
+func TestWatcherCloseFromHandler(t *testing.T) {
+	dirPath, cleanup := testWatcherDir(t)
+	defer cleanup()
+	before := runtime.NumGoroutine()
+	var w *Watcher
+	w = newTestWatcher(t, dirPath, WatchBackendPoll, func(WatchEvent) { go w.Close() })
+	stopped := make(chan struct{})
+	go func() { w.Go(); close(stopped) }()
+	time.Sleep(50 * time.Millisecond)
+	if err := ioutil.WriteFile(filepath.Join(dirPath, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	w.Poll()
+	waitOrFail(t, stopped, "Close from handler")
+	checkNoLeakedGoroutines(t, before)
+}
+
+//	Returns whether an event for `path` including all of `op` was collected.
+func (me *testWatchEvents) has(path string, op WatchOp) bool {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	for _, evt := range me.evts {
+		if evt.Path == path && evt.Op.Has(op) {
+			return true
+		}
+	}
+	return false
+}
+
+//	Fails `t` unless `cond` becomes `true` soon.
+func waitForOrFail(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for start := time.Now(); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("timed out waiting for " + what)
+		}
+	}
+}
+
+func TestWatcherTree(t *testing.T) {
+	for _, backend := range []WatchBackend{WatchBackendAuto, WatchBackendPoll} {
+		dirPath, cleanup := testWatcherDir(t)
+		skipped := filepath.Join(dirPath, "skipped")
+		if err := os.Mkdir(skipped, 0755); err != nil {
+			t.Fatal(err)
+		}
+		var rec testWatchEvents
+		w, _ := NewWatcher()
+		w.Backend, w.PollIntervalNano = backend, (20 * time.Millisecond).Nanoseconds()
+		w.Ignore = NewIgnorer(dirPath)
+		w.Ignore.AddPatterns(dirPath, "skipped/")
+		if errs := w.WatchTree(dirPath, "*", false, rec.on); len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		stopped := make(chan struct{})
+		go func() { w.Go(); close(stopped) }()
+		watching := func(dirPath string) bool {
+			w.mutex.Lock()
+			defer w.mutex.Unlock()
+			return w.dirsWatching[dirPath]
+		}
+		if watching(skipped) {
+			t.Errorf("backend %d: ignored directory watched", backend)
+		}
+
+		// created together with the directories, so likely before `w` got to watch them
+		deep := filepath.Join(dirPath, "new", "deep")
+		if err := os.MkdirAll(deep, 0755); err != nil {
+			t.Fatal(err)
+		} else if err = ioutil.WriteFile(filepath.Join(deep, "early"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		waitForOrFail(t, "events in new directories", func() bool {
+			return rec.has(filepath.Join(dirPath, "new"), WatchCreate) && rec.has(deep, WatchCreate) && rec.has(filepath.Join(deep, "early"), WatchCreate)
+		})
+		if !watching(deep) {
+			t.Errorf("backend %d: new sub-directory not watched", backend)
+		}
+		if err := ioutil.WriteFile(filepath.Join(deep, "late"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		waitForOrFail(t, "event in watched new directory", func() bool { return rec.has(filepath.Join(deep, "late"), WatchCreate) })
+
+		if err := os.RemoveAll(filepath.Join(dirPath, "new")); err != nil {
+			t.Fatal(err)
+		}
+		waitForOrFail(t, "unwatching removed directories", func() bool { return !(watching(deep) || watching(filepath.Join(dirPath, "new"))) })
+		if err := ioutil.WriteFile(filepath.Join(skipped, "file"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		w.Poll()
+		time.Sleep(100 * time.Millisecond)
+		for _, evt := range rec.take() {
+			if watchIsInDir(evt.Path, skipped) {
+				t.Errorf("backend %d: event for ignored path: %v", backend, evt)
+			}
+		}
+		w.Close()
+		waitOrFail(t, stopped, "Go after Close")
+		cleanup()
+	}
+}
+
+//	Collects the batches passed to its `on` handler.
+type testWatchBatches struct {
+	mutex   sync.Mutex
+	batches [][]WatchEvent
+}
+
+func (me *testWatchBatches) on(evts []WatchEvent) {
+	me.mutex.Lock()
+	me.batches = append(me.batches, evts)
+	me.mutex.Unlock()
+}
+
+func (me *testWatchBatches) take() (batches [][]WatchEvent) {
+	me.mutex.Lock()
+	batches, me.batches = me.batches, nil
+	me.mutex.Unlock()
+	return
+}
+
+func (me *testWatchBatches) count() int {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return len(me.batches)
+}
+
+func TestWatcherBatched(t *testing.T) {
+	dirPath, cleanup := testWatcherDir(t)
+	defer cleanup()
+	write := func(name string, contents string) {
+		if err := ioutil.WriteFile(filepath.Join(dirPath, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var rec testWatchBatches
+	start := func(quiet time.Duration, maxLatency time.Duration, runHandlerNow bool) (w *Watcher, stopped chan struct{}) {
+		w, _ = NewWatcher()
+		w.Backend, w.PollIntervalNano = WatchBackendPoll, time.Hour.Nanoseconds() // only polling when told to
+		w.BatchQuietNano, w.BatchMaxLatencyNano = quiet.Nanoseconds(), maxLatency.Nanoseconds()
+		if errs := w.WatchBatched(dirPath, "*.txt", false, runHandlerNow, rec.on); len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		stopped = make(chan struct{})
+		go func() { w.Go(); close(stopped) }()
+		waitForOrFail(t, "Go to start", func() bool { // so that `Poll` and `Close` don't happen before it
+			w.mutex.Lock()
+			defer w.mutex.Unlock()
+			return w.stopped != nil
+		})
+		return
+	}
+	stop := func(w *Watcher, stopped chan struct{}) {
+		w.Close()
+		waitOrFail(t, stopped, "Go after Close")
+	}
+
+	// `runHandlerNow`, then de-duplication: several changes to one path within the quiet period make for one event
+	write("old.txt", "")
+	w, stopped := start(100*time.Millisecond, time.Hour, true)
+	if batches := rec.take(); !(len(batches) == 1 && reflect.DeepEqual(batches[0], []WatchEvent{{Path: filepath.Join(dirPath, "old.txt")}})) {
+		t.Errorf("runHandlerNow: %v", batches)
+	}
+	write("new.txt", "1")
+	write("other.go", "1")
+	w.Poll()
+	write("new.txt", "22")
+	w.Poll()
+	waitForOrFail(t, "batch", func() bool { return rec.count() > 0 })
+	time.Sleep(200 * time.Millisecond)
+	if batches := rec.take(); !(len(batches) == 1 && reflect.DeepEqual(batches[0], []WatchEvent{{Path: filepath.Join(dirPath, "new.txt"), Op: WatchCreate | WatchWrite}})) {
+		t.Errorf("de-duplicated batch: %v", batches)
+	}
+	stop(w, stopped)
+
+	// `BatchMaxLatencyNano`: changes coming faster than `BatchQuietNano` still get delivered periodically
+	w, stopped = start(500*time.Millisecond, 150*time.Millisecond, false)
+	for i := 0; i < 40; i++ {
+		write("new.txt", strings.Repeat("x", i))
+		w.Poll()
+		time.Sleep(25 * time.Millisecond)
+	}
+	if n := rec.count(); n < 2 {
+		t.Errorf("%d batches delivered during 1s of changes every 25ms with a maximum latency of 150ms", n)
+	}
+	stop(w, stopped)
+	rec.take()
+
+	// `Close` delivers the pending batch
+	w, stopped = start(time.Hour, time.Hour, false)
+	write("last.txt", "")
+	w.Poll()
+	stop(w, stopped)
+	if batches := rec.take(); !(len(batches) == 1 && reflect.DeepEqual(batches[0], []WatchEvent{{Path: filepath.Join(dirPath, "last.txt"), Op: WatchCreate}})) {
+		t.Errorf("batch pending on Close: %v", batches)
+	}
+}
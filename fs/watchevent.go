@@ -0,0 +1,64 @@
+package ufs
+
+import (
+	"strings"
+)
+
+//	The kinds of file-system changes reported in a `WatchEvent`, combinable as bit flags.
+type WatchOp uint32
+
+const (
+	//	A file or directory was created (or moved or renamed into place).
+	WatchCreate WatchOp = 1 << iota
+
+	//	A file's contents were written to.
+	WatchWrite
+
+	//	A file or directory was deleted.
+	WatchRemove
+
+	//	A file or directory was moved or renamed away (the event carries its old path).
+	WatchRename
+
+	//	A file's or directory's permissions or other metadata changed.
+	WatchChmod
+)
+
+//	Returns whether `me` includes all the bits in `op`.
+func (me WatchOp) Has(op WatchOp) bool {
+	return me&op == op && op != 0
+}
+
+//	Returns for example `CREATE|WRITE`, or `NONE` for `0`.
+func (me WatchOp) String() string {
+	var names []string
+	for i, name := range []string{"CREATE", "WRITE", "REMOVE", "RENAME", "CHMOD"} {
+		if me&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "NONE"
+	}
+	return strings.Join(names, "|")
+}
+
+//	A file-system change observed by a `Watcher` and passed to `WatcherHandler`s.
+type WatchEvent struct {
+	//	The full path of the file or directory that changed.
+	Path string
+
+	//	What happened. `0` for the one-off handler invocations of `runHandlerNow`, which report no actual change.
+	Op WatchOp
+
+	//	Whether `Path` is (for `WatchRemove` and `WatchRename`: was) a directory.
+	IsDir bool
+}
+
+//	Returns for example `"/tmp/foo.txt": CREATE`.
+func (me WatchEvent) String() string {
+	return "\"" + me.Path + "\": " + me.Op.String()
+}
+
+//	Handles a batch of file-system notifications originating in a `Watcher`, see `Watcher.WatchBatched`.
+type WatcherBatchHandler func(evts []WatchEvent)
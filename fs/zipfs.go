@@ -0,0 +1,95 @@
+package ufs
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/metaleap/go-util"
+)
+
+//	A read-only `Fs` (see `MemFs`) of the contents of a zip archive: entries are decompressed on their
+//	first `Open` and then kept in memory. Directories missing from the archive are implied by their contents
+//	(and have the newest modification time of those).
+type ZipFs struct {
+	*MemFs
+	closer io.Closer
+}
+
+//	Opens the zip archive at `zipFilePath` as a `ZipFs`, which must be `Close`d when done.
+func OpenZipFs(zipFilePath string) (me *ZipFs, err error) {
+	var zr *zip.ReadCloser
+	if zr, err = zip.OpenReader(zipFilePath); err == nil {
+		if me, err = NewZipFs(&zr.Reader); err != nil {
+			zr.Close()
+		} else {
+			me.closer = zr
+		}
+	}
+	return
+}
+
+//	Returns a `ZipFs` of `zipReader`, which must remain readable for as long as the `ZipFs` is used.
+//	Entries with paths outside the archive's root (such as `../x`) are an `error`.
+func NewZipFs(zipReader *zip.Reader) (me *ZipFs, err error) {
+	me = &ZipFs{MemFs: NewMemFs()}
+	me.root.modTime = time.Time{}
+	implied := map[*memNode]bool{me.root: true} // these get the newest modification time of their contents
+	for _, zf := range zipReader.File {
+		name := strings.Replace(zf.Name, "\\", "/", -1)
+		if cleaned := path.Clean(name); path.IsAbs(name) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return nil, umisc.E("zip entry outside of archive root: " + zf.Name)
+		}
+		info, parts := zf.FileInfo(), memFsParts(name)
+		dir, ancestors := me.root, []*memNode{me.root}
+		for _, part := range parts[:len(parts)-1] {
+			if child := dir.children[part]; child != nil && child.mode.IsDir() {
+				dir = child
+			} else {
+				child = &memNode{name: part, mode: os.ModeDir | 0755, children: map[string]*memNode{}}
+				dir.children[part], dir, implied[child] = child, child, true
+			}
+			ancestors = append(ancestors, dir)
+		}
+		for _, ancestor := range ancestors {
+			if implied[ancestor] && ancestor.modTime.Before(info.ModTime()) {
+				ancestor.modTime = info.ModTime()
+			}
+		}
+		last := parts[len(parts)-1]
+		if last == "" { // the root itself
+			continue
+		}
+		node := dir.children[last]
+		if node == nil || !(node.mode.IsDir() && info.IsDir()) {
+			node = &memNode{name: last}
+			dir.children[last] = node
+		}
+		node.mode, node.modTime = info.Mode(), info.ModTime()
+		delete(implied, node)
+		if info.IsDir() && node.children == nil {
+			node.children = map[string]*memNode{}
+		} else if info.Mode()&os.ModeSymlink != 0 {
+			tmp := &memNode{zipFile: zf}
+			if err = me.unzip(tmp); err != nil {
+				return nil, err
+			}
+			node.link = string(tmp.data)
+		} else if !info.IsDir() {
+			node.zipFile, node.data = zf, nil
+		}
+	}
+	me.readOnly = true
+	return
+}
+
+//	Closes the underlying zip archive if `me` was obtained from `OpenZipFs`.
+func (me *ZipFs) Close() (err error) {
+	if me.closer != nil {
+		err = me.closer.Close()
+	}
+	return
+}
@@ -0,0 +1,156 @@
+package umisc
+
+import (
+	"fmt"
+)
+
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	(Both are evaluated either way: for lazy evaluation, use a plain `if`.)
+func If[T any](cond bool, ifTrue T, ifFalse T) T {
+	if cond {
+		return ifTrue
+	}
+	return ifFalse
+}
+
+//	Returns the first of `vals` that isn't the zero value of `T`, or else the zero value.
+func Coalesce[T comparable](vals ...T) (val T) {
+	var zero T
+	for _, val = range vals {
+		if val != zero {
+			return
+		}
+	}
+	return zero
+}
+
+//	Returns `val` if `err` is `nil`, otherwise panics with `err`.
+//	Mostly for one-off `package main`s and package-level `var` initializations.
+func Must[T any](val T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+//	An optional value: either `Some` value of type `T` or `None`.
+//	The zero `Option` is `None`.
+type Option[T any] struct {
+	val T
+	ok  bool
+}
+
+//	Returns an `Option` holding `val`.
+func Some[T any](val T) Option[T] {
+	return Option[T]{val: val, ok: true}
+}
+
+//	Returns an empty `Option`.
+func None[T any]() (none Option[T]) {
+	return
+}
+
+//	Returns an `Option` holding `val` if `ok`, otherwise `None`, for the "comma ok" idiom: `OptionOf(m[k])`.
+func OptionOf[T any](val T, ok bool) Option[T] {
+	if !ok {
+		return None[T]()
+	}
+	return Some(val)
+}
+
+//	Returns the value held by `me`, if any, and whether there is one.
+func (me Option[T]) Get() (T, bool) {
+	return me.val, me.ok
+}
+
+//	Returns whether `me` holds a value.
+func (me Option[T]) IsSome() bool {
+	return me.ok
+}
+
+//	Returns whether `me` holds no value.
+func (me Option[T]) IsNone() bool {
+	return !me.ok
+}
+
+//	Returns the value held by `me`, or panics if there is none.
+func (me Option[T]) MustGet() T {
+	if !me.ok {
+		panic(fmt.Sprintf("umisc.Option[%T].MustGet: no value", me.val))
+	}
+	return me.val
+}
+
+//	Returns the value held by `me`, or `defaultVal` if there is none.
+func (me Option[T]) Or(defaultVal T) T {
+	if me.ok {
+		return me.val
+	}
+	return defaultVal
+}
+
+//	Returns the value held by `me`, or else the result of calling `orElse`.
+func (me Option[T]) OrElse(orElse func() T) T {
+	if me.ok {
+		return me.val
+	}
+	return orElse()
+}
+
+//	Implements `fmt.Stringer`.
+func (me Option[T]) String() string {
+	if me.ok {
+		return fmt.Sprintf("Some(%v)", me.val)
+	}
+	return "None"
+}
+
+//	Either a successful value of type `T` or an `error`, for passing results through channels,
+//	slices or maps, or for deferring error checks. The zero `Result` is `Ok` with the zero value.
+type Result[T any] struct {
+	Val T
+	Err error
+}
+
+//	Returns a successful `Result` holding `val`.
+func Ok[T any](val T) Result[T] {
+	return Result[T]{Val: val}
+}
+
+//	Returns a failed `Result` holding `err`.
+func Fail[T any](err error) Result[T] {
+	return Result[T]{Err: err}
+}
+
+//	Returns a `Result` from the usual `(val, err)` return pair: `ResultOf(strconv.Atoi(s))`.
+func ResultOf[T any](val T, err error) Result[T] {
+	return Result[T]{Val: val, Err: err}
+}
+
+//	Returns `me.Val` and `me.Err`.
+func (me Result[T]) Get() (T, error) {
+	return me.Val, me.Err
+}
+
+//	Returns whether `me.Err` is `nil`.
+func (me Result[T]) IsOk() bool {
+	return me.Err == nil
+}
+
+//	Returns `me.Val` if `me.Err` is `nil`, otherwise panics with `me.Err`.
+func (me Result[T]) MustGet() T {
+	return Must(me.Val, me.Err)
+}
+
+//	Returns `me.Val` if `me.Err` is `nil`, otherwise `defaultVal`.
+func (me Result[T]) Or(defaultVal T) T {
+	if me.Err == nil {
+		return me.Val
+	}
+	return defaultVal
+}
+
+//	Returns `Some(me.Val)` if `me.Err` is `nil`, otherwise `None`.
+func (me Result[T]) Option() Option[T] {
+	return OptionOf(me.Val, me.Err == nil)
+}
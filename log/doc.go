@@ -0,0 +1,2 @@
+// Go programming helpers for common logging needs: a small levelled, structured logger with pluggable sinks.
+package ulog
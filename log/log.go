@@ -0,0 +1,183 @@
+package ulog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+//	The severity of an `Entry`.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+
+	//	When set via `Logger.SetLevel`, silences all logging.
+	LevelOff
+)
+
+var levelNames = [...]string{"DEBUG", "INFO", "WARN", "ERROR", "OFF"}
+
+func (me Level) String() string {
+	if me >= 0 && int(me) < len(levelNames) {
+		return levelNames[me]
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(me))
+}
+
+//	A key/value pair attached to an `Entry`.
+type Field struct {
+	Key string
+	Val interface{}
+}
+
+//	A single log record, as handed to all `Sink`s.
+type Entry struct {
+	Time  time.Time
+	Level Level
+
+	//	The component that logged the `Entry`, such as `ufs.Watcher`, as set via `Logger.Sub`.
+	Src string
+
+	Msg    string
+	Fields []Field
+}
+
+//	Receives every `Entry` logged (at or above the `Level`) by the `Logger`s it's registered with.
+//	Implementations need to be safe for concurrent use.
+type Sink interface {
+	Log(entry *Entry)
+}
+
+//	A levelled, structured logger. All `Logger`s derived via `Sub` or `With` share their
+//	`Level` and `Sink`s with their parent, so that silencing or redirecting a root `Logger`
+//	also applies to all sub-`Logger`s (such as the `Log` vars of the other go-util packages).
+type Logger struct {
+	core   *loggerCore
+	src    string
+	fields []Field
+}
+
+type loggerCore struct {
+	sync.RWMutex
+	level Level
+	sinks []Sink
+}
+
+var (
+	//	The root `Logger` that all go-util packages log to (via their own `Sub`-`Logger`s).
+	//	By default, writes `LevelWarn` and above as text to `os.Stderr`.
+	Default = New(LevelWarn, &TextSink{W: os.Stderr})
+)
+
+//	Returns a new root `Logger` writing `Entry`s at or above `level` to the specified `sinks`.
+func New(level Level, sinks ...Sink) *Logger {
+	return &Logger{core: &loggerCore{level: level, sinks: sinks}}
+}
+
+//	Returns a new `Logger` sharing `me`'s `Level` and `Sink`s, whose `Entry`s have the specified
+//	`Src` (appended to `me`'s own, if any, with a `.` in between) and additional `keyVals` fields.
+func (me *Logger) Sub(src string, keyVals ...interface{}) *Logger {
+	if len(me.src) > 0 && len(src) > 0 {
+		src = me.src + "." + src
+	} else if len(src) == 0 {
+		src = me.src
+	}
+	return &Logger{core: me.core, src: src, fields: append(me.fields[:len(me.fields):len(me.fields)], fields(keyVals)...)}
+}
+
+//	Returns a new `Logger` like `me`, but with additional `keyVals` fields for all its `Entry`s.
+func (me *Logger) With(keyVals ...interface{}) *Logger {
+	return me.Sub("", keyVals...)
+}
+
+//	Returns whether `Entry`s of the specified `level` are currently being logged.
+func (me *Logger) Enabled(level Level) bool {
+	me.core.RLock()
+	defer me.core.RUnlock()
+	return level >= me.core.level && level < LevelOff && len(me.core.sinks) > 0
+}
+
+//	Returns the minimum `Level` of `Entry`s being logged.
+func (me *Logger) Level() Level {
+	me.core.RLock()
+	defer me.core.RUnlock()
+	return me.core.level
+}
+
+//	Sets the minimum `Level` of `Entry`s being logged by `me`, its parent and all its sub-`Logger`s.
+func (me *Logger) SetLevel(level Level) {
+	me.core.Lock()
+	me.core.level = level
+	me.core.Unlock()
+}
+
+//	Replaces all `Sink`s of `me`, its parent and all its sub-`Logger`s.
+func (me *Logger) SetSinks(sinks ...Sink) {
+	me.core.Lock()
+	me.core.sinks = sinks
+	me.core.Unlock()
+}
+
+//	Adds a `Sink` to `me`, its parent and all its sub-`Logger`s.
+func (me *Logger) AddSink(sink Sink) {
+	me.core.Lock()
+	me.core.sinks = append(me.core.sinks[:len(me.core.sinks):len(me.core.sinks)], sink)
+	me.core.Unlock()
+}
+
+//	Logs `msg` at `LevelDebug`, with the optional alternating key/value pairs in `keyVals`.
+func (me *Logger) Debug(msg string, keyVals ...interface{}) { me.Log(LevelDebug, msg, keyVals...) }
+
+//	Logs `msg` at `LevelInfo`, with the optional alternating key/value pairs in `keyVals`.
+func (me *Logger) Info(msg string, keyVals ...interface{}) { me.Log(LevelInfo, msg, keyVals...) }
+
+//	Logs `msg` at `LevelWarn`, with the optional alternating key/value pairs in `keyVals`.
+func (me *Logger) Warn(msg string, keyVals ...interface{}) { me.Log(LevelWarn, msg, keyVals...) }
+
+//	Logs `msg` at `LevelError`, with the optional alternating key/value pairs in `keyVals`.
+func (me *Logger) Error(msg string, keyVals ...interface{}) { me.Log(LevelError, msg, keyVals...) }
+
+//	If `err` isn't `nil`, logs its message at `LevelError`, with the optional alternating key/value pairs in `keyVals`.
+func (me *Logger) Err(err error, keyVals ...interface{}) {
+	if err != nil {
+		me.Log(LevelError, err.Error(), keyVals...)
+	}
+}
+
+//	Logs `msg` at the specified `level`, with the optional alternating key/value pairs in `keyVals`.
+func (me *Logger) Log(level Level, msg string, keyVals ...interface{}) {
+	me.core.RLock()
+	sinks := me.core.sinks
+	enabled := level >= me.core.level && level < LevelOff
+	me.core.RUnlock()
+	if enabled && len(sinks) > 0 {
+		entry := &Entry{Time: time.Now(), Level: level, Src: me.src, Msg: msg, Fields: me.fields}
+		if len(keyVals) > 0 {
+			entry.Fields = append(me.fields[:len(me.fields):len(me.fields)], fields(keyVals)...)
+		}
+		for _, sink := range sinks {
+			sink.Log(entry)
+		}
+	}
+}
+
+func fields(keyVals []interface{}) (fields []Field) {
+	if len(keyVals) > 0 {
+		fields = make([]Field, 0, (len(keyVals)+1)/2)
+		for i := 0; i < len(keyVals); i += 2 {
+			field := Field{Key: fmt.Sprint(keyVals[i])}
+			if i < len(keyVals)-1 {
+				field.Val = keyVals[i+1]
+			} else {
+				field.Key, field.Val = "!BADKEY", keyVals[i]
+			}
+			fields = append(fields, field)
+		}
+	}
+	return
+}
@@ -0,0 +1,164 @@
+package ulog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//	A `Sink` writing each `Entry` as a single human-readable text line to `W`,
+//	such as: `2017/08/30 14:27:32 WARN ufs.Watcher: msg key1=val1 key2="val 2"`.
+type TextSink struct {
+	W io.Writer
+
+	//	The `time.Format` layout used for `Entry.Time`, defaults to `2006/01/02 15:04:05` if empty.
+	TimeFormat string
+
+	mutex sync.Mutex
+}
+
+//	Implements `Sink.Log`.
+func (me *TextSink) Log(entry *Entry) {
+	var buf bytes.Buffer
+	timeformat := me.TimeFormat
+	if len(timeformat) == 0 {
+		timeformat = "2006/01/02 15:04:05"
+	}
+	buf.WriteString(entry.Time.Format(timeformat))
+	buf.WriteByte(' ')
+	buf.WriteString(entry.Level.String())
+	buf.WriteByte(' ')
+	if len(entry.Src) > 0 {
+		buf.WriteString(entry.Src)
+		buf.WriteString(": ")
+	}
+	buf.WriteString(entry.Msg)
+	for _, field := range entry.Fields {
+		buf.WriteByte(' ')
+		buf.WriteString(field.Key)
+		buf.WriteByte('=')
+		if s := fmt.Sprint(field.Val); len(s) == 0 || strings.ContainsAny(s, " \t\r\n\"=") {
+			buf.WriteString(strconv.Quote(s))
+		} else {
+			buf.WriteString(s)
+		}
+	}
+	buf.WriteByte('\n')
+	me.mutex.Lock()
+	me.W.Write(buf.Bytes())
+	me.mutex.Unlock()
+}
+
+//	A `Sink` writing each `Entry` as a single-line JSON object to `W` ("JSON lines"), such as:
+//	`{"time":"2017-08-30T14:27:32.123+02:00","level":"WARN","src":"ufs.Watcher","msg":"msg","key1":"val1"}`.
+//	Field values that aren't JSON-encodable are written as their `fmt.Sprint` representation.
+type JsonSink struct {
+	W io.Writer
+
+	mutex sync.Mutex
+}
+
+//	Implements `Sink.Log`.
+func (me *JsonSink) Log(entry *Entry) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	jsonWrite(&buf, entry.Time.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	jsonWrite(&buf, entry.Level.String())
+	if len(entry.Src) > 0 {
+		buf.WriteString(`,"src":`)
+		jsonWrite(&buf, entry.Src)
+	}
+	buf.WriteString(`,"msg":`)
+	jsonWrite(&buf, entry.Msg)
+	for _, field := range entry.Fields {
+		buf.WriteByte(',')
+		jsonWrite(&buf, field.Key)
+		buf.WriteByte(':')
+		val := field.Val
+		if err, iserr := val.(error); iserr {
+			val = err.Error()
+		}
+		jsonWrite(&buf, val)
+	}
+	buf.WriteString("}\n")
+	me.mutex.Lock()
+	me.W.Write(buf.Bytes())
+	me.mutex.Unlock()
+}
+
+func jsonWrite(buf *bytes.Buffer, val interface{}) {
+	if data, err := json.Marshal(val); err == nil {
+		buf.Write(data)
+	} else {
+		data, _ = json.Marshal(fmt.Sprint(val))
+		buf.Write(data)
+	}
+}
+
+//	The capacity of a zero `RingSink`, as opposed to one returned by `NewRingSink`.
+const RingSinkDefaultCapacity = 256
+
+//	A `Sink` keeping (only) the most recent `Entry`s in memory, such as for tests,
+//	diagnostics dumps or for displaying recent library messages in a tool's UI.
+//	The zero `RingSink` is ready to use and retains the latest `RingSinkDefaultCapacity` `Entry`s.
+type RingSink struct {
+	mutex   sync.Mutex
+	entries []*Entry
+	next    int
+	full    bool
+}
+
+//	Returns a new `RingSink` retaining the latest `capacity` `Entry`s.
+func NewRingSink(capacity int) *RingSink {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &RingSink{entries: make([]*Entry, capacity)}
+}
+
+//	Implements `Sink.Log`.
+func (me *RingSink) Log(entry *Entry) {
+	me.mutex.Lock()
+	if len(me.entries) == 0 {
+		me.entries = make([]*Entry, RingSinkDefaultCapacity)
+	}
+	me.entries[me.next] = entry
+	if me.next = (me.next + 1) % len(me.entries); me.next == 0 {
+		me.full = true
+	}
+	me.mutex.Unlock()
+}
+
+//	Returns all currently retained `Entry`s, oldest first.
+func (me *RingSink) Entries() (entries []*Entry) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if me.full {
+		entries = append(entries, me.entries[me.next:]...)
+	}
+	return append(entries, me.entries[:me.next]...)
+}
+
+//	Removes all currently retained `Entry`s.
+func (me *RingSink) Clear() {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	for i := range me.entries {
+		me.entries[i] = nil
+	}
+	me.next, me.full = 0, false
+}
+
+//	A `Sink` calling a func for every `Entry`. Must be safe for concurrent use.
+type SinkFunc func(entry *Entry)
+
+//	Implements `Sink.Log`.
+func (me SinkFunc) Log(entry *Entry) {
+	me(entry)
+}
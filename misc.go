@@ -6,10 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/metaleap/go-util/log"
 )
 
 var (
@@ -35,94 +36,63 @@ func Str(thing interface{}) string {
 	return fmt.Sprint(thing)
 }
 
-//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`. Equivalent to the generic `If`.
 func IfB(cond, ifTrue, ifFalse bool) bool {
-	if cond {
-		return ifTrue
-	}
-	return ifFalse
+	return If(cond, ifTrue, ifFalse)
 }
 
-//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`. Equivalent to the generic `If`.
 func IfF64(cond bool, ifTrue, ifFalse float64) float64 {
-	if cond {
-		return ifTrue
-	}
-	return ifFalse
+	return If(cond, ifTrue, ifFalse)
 }
 
-//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`. Equivalent to the generic `If`.
 func IfI(cond bool, ifTrue, ifFalse int) int {
-	if cond {
-		return ifTrue
-	}
-	return ifFalse
+	return If(cond, ifTrue, ifFalse)
 }
 
-//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`. Equivalent to the generic `If`.
 func IfI16(cond bool, ifTrue, ifFalse int16) int16 {
-	if cond {
-		return ifTrue
-	}
-	return ifFalse
+	return If(cond, ifTrue, ifFalse)
 }
 
-//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`. Equivalent to the generic `If`.
 func IfI32(cond bool, ifTrue, ifFalse int32) int32 {
-	if cond {
-		return ifTrue
-	}
-	return ifFalse
+	return If(cond, ifTrue, ifFalse)
 }
 
-//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`. Equivalent to the generic `If`.
 func IfI64(cond bool, ifTrue, ifFalse int64) int64 {
-	if cond {
-		return ifTrue
-	}
-	return ifFalse
+	return If(cond, ifTrue, ifFalse)
 }
 
-//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`. Equivalent to the generic `If`.
 func IfS(cond bool, ifTrue string, ifFalse string) string {
-	if cond {
-		return ifTrue
-	}
-	return ifFalse
+	return If(cond, ifTrue, ifFalse)
 }
 
-//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`. Equivalent to the generic `If`.
 func IfU32(cond bool, ifTrue, ifFalse uint32) uint32 {
-	if cond {
-		return ifTrue
-	}
-	return ifFalse
+	return If(cond, ifTrue, ifFalse)
 }
 
-//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`. Equivalent to the generic `If`.
 func IfU64(cond bool, ifTrue, ifFalse uint64) uint64 {
-	if cond {
-		return ifTrue
-	}
-	return ifFalse
+	return If(cond, ifTrue, ifFalse)
 }
 
-//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`. Equivalent to the generic `If`.
 func IfW(cond bool, ifTrue, ifFalse io.Writer) io.Writer {
-	if cond {
-		return ifTrue
-	}
-	return ifFalse
+	return If(cond, ifTrue, ifFalse)
 }
 
-//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`. Equivalent to the generic `If`.
 func IfX(cond bool, ifTrue, ifFalse interface{}) interface{} {
-	if cond {
-		return ifTrue
-	}
-	return ifFalse
+	return If(cond, ifTrue, ifFalse)
 }
 
+//	Decodes the JSON file at `fromfilepath` into `into`.
+//	For JSONC, defaults, env expansion and line-aware errors, see the `ucfg` package.
 func JsonDecodeFromFile(fromfilepath string, into interface{}) (err error) {
 	var f *os.File
 	if f, err = os.Open(fromfilepath); err == nil {
@@ -132,6 +102,8 @@ func JsonDecodeFromFile(fromfilepath string, into interface{}) (err error) {
 	return
 }
 
+//	Encodes `from` as JSON into the file at `tofilepath`, writing in place.
+//	For crash-safe writing, see `ufs.JsonEncodeToFileAtomic`.
 func JsonEncodeToFile(from interface{}, tofilepath string) (err error) {
 	var f *os.File
 	if f, err = os.Create(tofilepath); err == nil {
@@ -141,15 +113,16 @@ func JsonEncodeToFile(from interface{}, tofilepath string) (err error) {
 	return
 }
 
-//	A convenience short-hand for `log.Println(fmt.Sprintf(LogErrorFormat, err))` if `err` isn't `nil`.
+//	A convenience short-hand for `ulog.Default.Error(fmt.Sprintf(LogErrorFormat, err))` if `err` isn't `nil`.
 func LogError(err error) {
 	if err != nil {
-		log.Println(Strf(LogErrorFormat, err))
+		ulog.Default.Error(Strf(LogErrorFormat, err))
 	}
 }
 
 //	Attempts to extract major and minor version components from a string that begins with a version number.
 //	Example: returns []int{3, 2} and float64(3.2) for a `verstr` that is `3.2.0 - Build 8.15.10.2761`.
+//	Note that `both` is lossy (1.10 ranks below 1.9): for precise comparisons, use `ParseSemVerLoose`.
 func ParseVersion(verstr string) (majorMinor [2]int, both float64) {
 	var (
 		pos, j int
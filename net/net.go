@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 
@@ -62,6 +63,27 @@ func DownloadFile(srcFileUrl, dstFilePath string) (err error) {
 	return
 }
 
+//	Downloads the remote archive at the specified (`net/http`-compatible) `srcFileUrl` and extracts it to `dstDirPath`
+//	as it arrives (via `ufs.ReadArchive`), without saving it first. If `opts.Format` is `ufs.ArchiveAuto`, it's determined
+//	from the URL's path via `ufs.ArchiveFormatOf`, or else from the contents.
+func DownloadArchive(srcFileUrl string, dstDirPath string, opts *ufs.ArchiveOptions) (err error) {
+	var o ufs.ArchiveOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Format == ufs.ArchiveAuto {
+		if u, errurl := url.Parse(srcFileUrl); errurl == nil {
+			o.Format = ufs.ArchiveFormatOf(u.Path)
+		}
+	}
+	var rc io.ReadCloser
+	if rc, err = OpenRemoteFile(srcFileUrl); err == nil {
+		defer rc.Close()
+		err = ufs.ReadArchive(rc, dstDirPath, &o)
+	}
+	return
+}
+
 //	Opens a remote file at the specified (`net/http`-compatible) `srcFileUrl` and returns its `io.ReadCloser`.
 func OpenRemoteFile(srcFileUrl string) (src io.ReadCloser, err error) {
 	var resp *http.Response
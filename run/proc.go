@@ -4,11 +4,20 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
 	"sync"
+
+	"github.com/metaleap/go-util/log"
+	"github.com/metaleap/go-util/str"
+)
+
+var (
+	//	The `ulog.Logger` that this package reports to.
+	Log = ulog.Default.Sub("urun")
 )
 
 type CmdTry struct {
@@ -77,6 +86,7 @@ func CmdExecStdin(stdin string, dir string, cmdname string, cmdargs ...string) (
 		cmdname = cmdargs[0]
 		cmdargs = cmdargs[1:]
 	}
+	Log.Debug("exec", "cmd", cmdname, "args", cmdargs, "dir", dir)
 	cmd := exec.Command(cmdname, cmdargs...)
 	cmd.Dir = dir
 	if len(stdin) > 0 {
@@ -88,6 +98,8 @@ func CmdExecStdin(stdin string, dir string, cmdname string, cmdargs ...string) (
 	if err = cmd.Run(); err != nil {
 		if _, isexiterr := err.(*exec.ExitError); isexiterr || strings.Contains(err.Error(), "pipe has been ended") || strings.Contains(err.Error(), "pipe has been closed") {
 			err = nil
+		} else {
+			Log.Debug("exec failed", "cmd", cmdname, "err", err)
 		}
 	}
 	stdout = bufout.String()
@@ -96,6 +108,7 @@ func CmdExecStdin(stdin string, dir string, cmdname string, cmdargs ...string) (
 }
 
 func CmdExecIn(dir string, cmdname string, cmdargs ...string) (cmdout string, cmderr string, err error) {
+	Log.Debug("exec", "cmd", cmdname, "args", cmdargs, "dir", dir)
 	cmd := exec.Command(cmdname, cmdargs...)
 	cmd.Dir = dir
 
@@ -105,6 +118,8 @@ func CmdExecIn(dir string, cmdname string, cmdargs ...string) (cmdout string, cm
 	if stdout, err = cmd.Output(); err != nil {
 		if _, isexiterr := err.(*exec.ExitError); isexiterr || strings.Contains(err.Error(), "pipe has been ended") || strings.Contains(err.Error(), "pipe has been closed") {
 			err = nil
+		} else {
+			Log.Debug("exec failed", "cmd", cmdname, "err", err)
 		}
 	}
 	cmderr = strings.TrimSpace(stderr.String())
@@ -124,6 +139,30 @@ func CmdExec(cmdname string, cmdargs ...string) (string, string, error) {
 	return CmdExecIn("", cmdname, cmdargs...)
 }
 
+//	Splits `cmdLine` into its shell words (as per `ustr.SplitShellWords`, so without
+//	running a shell) for `CmdExec` and friends: the first is `cmdName`, the others `cmdArgs`.
+func CmdLineSplit(cmdLine string) (cmdName string, cmdArgs []string, err error) {
+	var words []string
+	if words, err = ustr.SplitShellWords(cmdLine); err == nil {
+		if len(words) == 0 {
+			err = errors.New("no command in command line: " + cmdLine)
+		} else {
+			cmdName, cmdArgs = words[0], words[1:]
+		}
+	}
+	return
+}
+
+//	Like `CmdExecIn`, but with `cmdLine` first split via `CmdLineSplit`.
+func CmdExecLineIn(dir string, cmdLine string) (cmdout string, cmderr string, err error) {
+	var cmdname string
+	var cmdargs []string
+	if cmdname, cmdargs, err = CmdLineSplit(cmdLine); err == nil {
+		cmdout, cmderr, err = CmdExecIn(dir, cmdname, cmdargs...)
+	}
+	return
+}
+
 // func CmdExecOr(def string, cmdname string, cmdargs ...string) string {
 // 	return CmdExecInOr(def, "", cmdname, cmdargs...)
 // }
@@ -0,0 +1,257 @@
+package umisc
+
+import (
+	"errors"
+	"strings"
+)
+
+//	A version-range constraint in the npm / bower syntax, as obtained via `ParseSemVerRange`.
+//	Supports comparators (`>=1.2.3`, `<2`, `=1.0.0`), X-ranges (`1.x`, `1.2.*`, `*`, `1.2`),
+//	tilde ranges (`~1.2.3`), caret ranges (`^0.3.1`), hyphen ranges (`1.2 - 2.3.4`),
+//	space-separated intersections (`>=1 <2`) and `||`-separated unions of all of those.
+type SemVerRange struct {
+	src  string
+	sets [][]semVerComparator
+}
+
+type semVerComparator struct {
+	op  string // one of: < <= > >= =
+	ver SemVer
+}
+
+//	The partially specified version in a range, such as `1`, `1.2`, `1.x` or `1.2.3-rc.1`.
+type semVerPartial struct {
+	nums [3]int
+	n    int // how many of `nums` are given, not wildcards
+	pre  []string
+}
+
+var semVerPre0 = []string{"0"}
+
+//	Parses the specified npm / bower-style version-range constraint. (See `SemVerRange` for syntax.)
+func ParseSemVerRange(rangestr string) (rng *SemVerRange, err error) {
+	rng = &SemVerRange{src: rangestr}
+	for _, setstr := range strings.Split(rangestr, "||") {
+		var set []semVerComparator
+		if set, err = parseSemVerRangeSet(setstr); err != nil {
+			return nil, errors.New("invalid version range `" + rangestr + "`: " + err.Error())
+		}
+		rng.sets = append(rng.sets, set)
+	}
+	return
+}
+
+func parseSemVerRangeSet(setstr string) (set []semVerComparator, err error) {
+	fields := strings.Fields(setstr)
+	for i := 0; i < len(fields); i++ { // normalize `>= 1.2` into `>=1.2`
+		if strings.Trim(fields[i], "<>=~^") == "" && i < len(fields)-1 {
+			fields[i], fields = fields[i]+fields[i+1], append(fields[:i+1], fields[i+2:]...)
+		}
+	}
+	if len(fields) == 3 && fields[1] == "-" {
+		var lo, hi semVerPartial
+		if lo, err = parseSemVerPartial(fields[0]); err == nil {
+			if hi, err = parseSemVerPartial(fields[2]); err == nil {
+				set = append(lo.comparators(">="), hi.comparators("<=")...)
+			}
+		}
+		return
+	}
+	for _, field := range fields {
+		op := field[:len(field)-len(strings.TrimLeft(field, "<>=~^"))]
+		var ver semVerPartial
+		if ver, err = parseSemVerPartial(field[len(op):]); err != nil {
+			return
+		}
+		switch op {
+		case "~", "~>":
+			set = append(set, ver.tilde()...)
+		case "^":
+			set = append(set, ver.caret()...)
+		case "", "=", "<", "<=", ">", ">=":
+			set = append(set, ver.comparators(op)...)
+		default:
+			return nil, errors.New("unknown operator `" + op + "`")
+		}
+	}
+	if len(set) == 0 {
+		set = []semVerComparator{{op: ">=", ver: SemVer{}}}
+	}
+	return
+}
+
+func parseSemVerPartial(s string) (ver semVerPartial, err error) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		if ver.pre, err = semVerIdents(s[i+1:], true); err != nil {
+			return
+		}
+		s = s[:i]
+	}
+	nums := strings.Split(s, ".")
+	if len(nums) > 3 {
+		err = errors.New("too many dot-separated numbers in `" + s + "`")
+		return
+	}
+	for i, num := range nums {
+		if num == "x" || num == "X" || num == "*" || num == "" {
+			break
+		} else if ver.nums[i], err = semVerNum(num); err != nil {
+			return
+		}
+		ver.n++
+	}
+	if ver.n < 3 {
+		ver.pre = nil
+	}
+	return
+}
+
+func (me semVerPartial) ver() SemVer {
+	return SemVer{Major: me.nums[0], Minor: me.nums[1], Patch: me.nums[2], Pre: me.pre}
+}
+
+//	Returns the lowest version not covered by `me`, as a `-0` pre-release (for use with `<`).
+func (me semVerPartial) next() (ver SemVer) {
+	ver.Pre = semVerPre0
+	switch me.n {
+	case 1:
+		ver.Major = me.nums[0] + 1
+	case 2:
+		ver.Major, ver.Minor = me.nums[0], me.nums[1]+1
+	default:
+		ver.Major, ver.Minor, ver.Patch = me.nums[0], me.nums[1], me.nums[2]+1
+	}
+	return
+}
+
+func (me semVerPartial) comparators(op string) []semVerComparator {
+	if me.n == 3 {
+		if op == "" {
+			op = "="
+		}
+		return []semVerComparator{{op: op, ver: me.ver()}}
+	} else if me.n == 0 {
+		if op == "<" || op == ">" {
+			return []semVerComparator{{op: "<", ver: SemVer{Pre: semVerPre0}}} // matches nothing
+		}
+		return []semVerComparator{{op: ">=", ver: SemVer{}}}
+	}
+	switch op {
+	case "<":
+		return []semVerComparator{{op: "<", ver: me.ver().withPre(semVerPre0)}}
+	case "<=":
+		return []semVerComparator{{op: "<", ver: me.next()}}
+	case ">":
+		return []semVerComparator{{op: ">=", ver: me.next().withPre(nil)}}
+	case ">=":
+		return []semVerComparator{{op: ">=", ver: me.ver()}}
+	}
+	return []semVerComparator{{op: ">=", ver: me.ver()}, {op: "<", ver: me.next()}}
+}
+
+func (me semVerPartial) tilde() []semVerComparator {
+	if me.n == 3 {
+		me.n = 2
+		return []semVerComparator{{op: ">=", ver: me.ver()}, {op: "<", ver: me.next()}}
+	}
+	return me.comparators("")
+}
+
+func (me semVerPartial) caret() []semVerComparator {
+	if me.n == 0 {
+		return me.comparators("")
+	}
+	lo := me.ver()
+	switch { // the upper bound bumps the left-most non-zero number of the given ones
+	case me.nums[0] != 0 || me.n == 1:
+		me.n = 1
+	case me.nums[1] != 0 || me.n == 2:
+		me.n = 2
+	}
+	return []semVerComparator{{op: ">=", ver: lo}, {op: "<", ver: me.next()}}
+}
+
+func (me SemVer) withPre(pre []string) SemVer {
+	me.Pre = pre
+	return me
+}
+
+func (me *semVerComparator) matches(ver SemVer) bool {
+	c := ver.Compare(me.ver)
+	switch me.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return c == 0
+}
+
+//	Returns whether `ver` satisfies `me`. As in npm, a pre-release `ver` only matches if at
+//	least one comparator in the matching intersection specifies a pre-release of the same
+//	MAJOR.MINOR.PATCH, so that `^1.2.3` matches `1.3.0` but not `1.3.0-beta`.
+func (me *SemVerRange) Matches(ver SemVer) bool {
+	for _, set := range me.sets {
+		allmatch, premeets := true, !ver.IsPre()
+		for i := range set {
+			if !set[i].matches(ver) {
+				allmatch = false
+				break
+			} else if cv := set[i].ver; !premeets && cv.IsPre() && !(len(cv.Pre) == 1 && cv.Pre[0] == "0" && set[i].op == "<") {
+				premeets = cv.Major == ver.Major && cv.Minor == ver.Minor && cv.Patch == ver.Patch
+			}
+		}
+		if allmatch && premeets {
+			return true
+		}
+	}
+	return false
+}
+
+//	Returns the highest of `vers` that `Matches` `me`, or `ok` as `false` if none do.
+func (me *SemVerRange) MaxMatching(vers ...SemVer) (max SemVer, ok bool) {
+	for _, ver := range vers {
+		if me.Matches(ver) && (!ok || max.Less(ver)) {
+			max, ok = ver, true
+		}
+	}
+	return
+}
+
+//	Returns the desugared form of `me`, such as `>=1.2.0 <2.0.0-0` for `^1.2`.
+func (me *SemVerRange) Normalized() string {
+	sets := make([]string, 0, len(me.sets))
+	for _, set := range me.sets {
+		comps := make([]string, 0, len(set))
+		for _, comp := range set {
+			comps = append(comps, comp.op+comp.ver.String())
+		}
+		sets = append(sets, strings.Join(comps, " "))
+	}
+	return strings.Join(sets, " || ")
+}
+
+//	Returns the original range string that `me` was parsed from.
+func (me *SemVerRange) String() string {
+	return me.src
+}
+
+//	Convenience short-hand for `ParseSemVerLoose(verstr)` then `ParseSemVerRange(rangestr)` and `SemVerRange.Matches`.
+func SemVerMatches(verstr string, rangestr string) (matches bool, err error) {
+	var ver SemVer
+	var rng *SemVerRange
+	if ver, err = ParseSemVerLoose(verstr); err == nil {
+		if rng, err = ParseSemVerRange(rangestr); err == nil {
+			matches = rng.Matches(ver)
+		}
+	}
+	return
+}
@@ -0,0 +1,187 @@
+package umisc
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+//	A SemVer 2.0 version (see http://semver.org), as obtained via `ParseSemVer` or `ParseSemVerLoose`.
+//	Unlike `ParseVersion`, no precision is lost: 1.10 is correctly greater than 1.9.
+type SemVer struct {
+	Major int
+	Minor int
+	Patch int
+
+	//	The dot-separated pre-release identifiers following the `-`, if any.
+	Pre []string
+
+	//	The dot-separated build-metadata identifiers following the `+`, if any. Ignored for ordering.
+	Build []string
+}
+
+//	Parses a strict SemVer 2.0 version string such as `1.2.3`, `1.0.0-rc.1+build.5` (an optional leading `v` is allowed).
+func ParseSemVer(verstr string) (ver SemVer, err error) {
+	s := strings.TrimPrefix(strings.TrimSpace(verstr), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		if ver.Build, err = semVerIdents(s[i+1:], false); err != nil {
+			return
+		}
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		if ver.Pre, err = semVerIdents(s[i+1:], true); err != nil {
+			return
+		}
+		s = s[:i]
+	}
+	if nums := strings.Split(s, "."); len(nums) != 3 {
+		err = errors.New("invalid semantic version `" + verstr + "`: expected MAJOR.MINOR.PATCH")
+	} else {
+		for i, dst := range []*int{&ver.Major, &ver.Minor, &ver.Patch} {
+			if *dst, err = semVerNum(nums[i]); err != nil {
+				err = errors.New("invalid semantic version `" + verstr + "`: " + err.Error())
+				break
+			}
+		}
+	}
+	return
+}
+
+//	Parses the (possibly incomplete or non-standard) version number that `verstr` begins with,
+//	such as `1.9` (for 1.9.0), `go1.10beta2` (for 1.10.0-beta2) or `3.2.0 - Build 8.15.10.2761`
+//	(for 3.2.0). Leading `v`, `=` or `go` and anything following the first space are ignored.
+func ParseSemVerLoose(verstr string) (ver SemVer, err error) {
+	s := strings.TrimSpace(verstr)
+	if i := strings.IndexAny(s, " \t"); i > 0 {
+		s = s[:i]
+	}
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(s, "go"), "="), "v")
+	core := s
+	if i := strings.IndexFunc(s, func(r rune) bool { return r != '.' && (r < '0' || r > '9') }); i >= 0 {
+		core, s = s[:i], s[i:]
+		if i = strings.IndexByte(s, '+'); i >= 0 {
+			ver.Build, _ = semVerIdents(s[i+1:], false)
+			s = s[:i]
+		}
+		if s = strings.TrimPrefix(s, "-"); len(s) > 0 {
+			if ver.Pre, err = semVerIdents(s, false); err != nil {
+				err = errors.New("invalid version `" + verstr + "`: " + err.Error())
+				return
+			}
+		}
+	}
+	if nums := strings.Split(strings.TrimSuffix(core, "."), "."); len(nums) > 3 {
+		err = errors.New("invalid version `" + verstr + "`: too many dot-separated numbers")
+	} else {
+		for i, dst := range []*int{&ver.Major, &ver.Minor, &ver.Patch}[:len(nums)] {
+			if *dst, err = strconv.Atoi(nums[i]); err != nil || *dst < 0 {
+				err = errors.New("invalid version `" + verstr + "`: expected a number instead of `" + nums[i] + "`")
+				break
+			}
+		}
+	}
+	return
+}
+
+func semVerNum(s string) (num int, err error) {
+	if len(s) > 1 && s[0] == '0' {
+		err = errors.New("leading zero in `" + s + "`")
+	} else if num, err = strconv.Atoi(s); err != nil || num < 0 || s[0] == '+' {
+		err = errors.New("expected a number instead of `" + s + "`")
+	}
+	return
+}
+
+func semVerIdents(s string, noLeadingZeros bool) (idents []string, err error) {
+	idents = strings.Split(s, ".")
+	for _, ident := range idents {
+		if len(ident) == 0 {
+			return nil, errors.New("empty identifier in `" + s + "`")
+		}
+		isnum := true
+		for _, r := range ident {
+			if !((r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '-') {
+				return nil, errors.New("invalid character in identifier `" + ident + "`")
+			} else if r < '0' || r > '9' {
+				isnum = false
+			}
+		}
+		if noLeadingZeros && isnum && len(ident) > 1 && ident[0] == '0' {
+			return nil, errors.New("leading zero in numeric identifier `" + ident + "`")
+		}
+	}
+	return
+}
+
+//	Returns -1 if `me` has lower precedence than `ver`, 1 if it has higher precedence, otherwise 0.
+//	As per SemVer 2.0, `Build` metadata is not considered.
+func (me SemVer) Compare(ver SemVer) int {
+	if c := cmpInt(me.Major, ver.Major); c != 0 {
+		return c
+	} else if c = cmpInt(me.Minor, ver.Minor); c != 0 {
+		return c
+	} else if c = cmpInt(me.Patch, ver.Patch); c != 0 {
+		return c
+	}
+	if len(me.Pre) == 0 || len(ver.Pre) == 0 {
+		return cmpInt(len(ver.Pre), len(me.Pre)) // a release outranks any of its pre-releases
+	}
+	for i := 0; i < len(me.Pre) && i < len(ver.Pre); i++ {
+		n1, e1 := strconv.ParseUint(me.Pre[i], 10, 64)
+		n2, e2 := strconv.ParseUint(ver.Pre[i], 10, 64)
+		if e1 == nil && e2 == nil {
+			if n1 != n2 {
+				return IfI(n1 < n2, -1, 1)
+			}
+		} else if e1 == nil || e2 == nil {
+			return IfI(e1 == nil, -1, 1) // numeric identifiers rank below alphanumeric ones
+		} else if c := strings.Compare(me.Pre[i], ver.Pre[i]); c != 0 {
+			return c
+		}
+	}
+	return cmpInt(len(me.Pre), len(ver.Pre))
+}
+
+func cmpInt(i1, i2 int) int {
+	if i1 < i2 {
+		return -1
+	} else if i1 > i2 {
+		return 1
+	}
+	return 0
+}
+
+//	Returns whether `me` has equal precedence to `ver` (ie. ignoring `Build` metadata).
+func (me SemVer) Eq(ver SemVer) bool {
+	return me.Compare(ver) == 0
+}
+
+//	Returns whether `me` has a pre-release version.
+func (me SemVer) IsPre() bool {
+	return len(me.Pre) > 0
+}
+
+//	Returns whether `me` has lower precedence than `ver`.
+func (me SemVer) Less(ver SemVer) bool {
+	return me.Compare(ver) < 0
+}
+
+//	Returns the canonical SemVer 2.0 representation of `me`, such as `1.0.0-rc.1+build.5`.
+func (me SemVer) String() (s string) {
+	s = strconv.Itoa(me.Major) + "." + strconv.Itoa(me.Minor) + "." + strconv.Itoa(me.Patch)
+	if len(me.Pre) > 0 {
+		s += "-" + strings.Join(me.Pre, ".")
+	}
+	if len(me.Build) > 0 {
+		s += "+" + strings.Join(me.Build, ".")
+	}
+	return
+}
+
+//	Implements `sort.Interface` for ordering by `SemVer.Compare`.
+type SemVers []SemVer
+
+func (me SemVers) Len() int           { return len(me) }
+func (me SemVers) Swap(i, j int)      { me[i], me[j] = me[j], me[i] }
+func (me SemVers) Less(i, j int) bool { return me[i].Less(me[j]) }
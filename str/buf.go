@@ -3,11 +3,29 @@ package ustr
 import (
 	"bytes"
 	"fmt"
+	"go/format"
+	"strings"
+	"text/template"
+	"unicode"
 )
 
-//	A convenient wrapper for `bytes.Buffer`.
+//	A convenient wrapper for `bytes.Buffer`, geared towards emitting source code:
+//	`Write` and `Writeln` (but not the embedded `bytes.Buffer` methods) prefix every non-empty line
+//	with the current `Indent` level, `Block` and `Braced` emit indented blocks, `Line` reports
+//	the current line and `PushSrc` / `PopSrc` / `SrcMap` record which input produced which line.
+//
+//	The zero `Buffer` is ready to use.
 type Buffer struct {
 	bytes.Buffer
+
+	//	Written at the beginning of every non-empty line once per `Indent` level. Defaults to a tab if empty.
+	IndentWith string
+
+	indent   int
+	srcs     []interface{}
+	srcLines []interface{}
+	numLines int
+	counted  int
 }
 
 //	Convenience short-hand for `bytes.Buffer.WriteString(fmt.Sprintf(format, args...))`
@@ -15,11 +33,178 @@ func (me *Buffer) Write(format string, args ...interface{}) {
 	if len(args) > 0 {
 		format = fmt.Sprintf(format, args...)
 	}
-	me.Buffer.WriteString(format)
+	me.write(format)
 }
 
 //	Convenience short-hand for `bytes.Buffer.WriteString(fmt.Sprintf(format+"\n", args...))`
 func (me *Buffer) Writeln(format string, args ...interface{}) {
 	me.Write(format, args...)
-	me.Buffer.WriteString("\n")
+	me.write("\n")
+}
+
+//	Executes `tmpl` with `data` and writes the result as `Write` would, so indented and source-mapped.
+func (me *Buffer) WriteTemplate(tmpl *template.Template, data interface{}) (err error) {
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, data); err == nil {
+		me.write(buf.String())
+	}
+	return
+}
+
+func (me *Buffer) write(s string) {
+	for len(s) > 0 {
+		ln := s
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			ln = s[:i+1]
+		}
+		if me.indent > 0 && ln != "\n" && ln != "\r\n" && me.atLineStart() {
+			indent := me.IndentWith
+			if len(indent) == 0 {
+				indent = "\t"
+			}
+			me.Buffer.WriteString(strings.Repeat(indent, me.indent))
+		}
+		if len(me.srcs) > 0 && strings.TrimSpace(ln) != "" {
+			if lnidx := me.Line() - 1; lnidx >= len(me.srcLines) {
+				me.srcLines = append(me.srcLines, make([]interface{}, 1+lnidx-len(me.srcLines))...)
+				me.srcLines[lnidx] = me.srcs[len(me.srcs)-1]
+			} else if me.srcLines[lnidx] == nil {
+				me.srcLines[lnidx] = me.srcs[len(me.srcs)-1]
+			}
+		}
+		me.Buffer.WriteString(ln)
+		s = s[len(ln):]
+	}
+}
+
+func (me *Buffer) atLineStart() bool {
+	l := me.Len()
+	return l == 0 || me.Bytes()[l-1] == '\n'
+}
+
+//	Increments the indentation level for all subsequent `Write`s and `Writeln`s.
+func (me *Buffer) Indent() {
+	me.indent++
+}
+
+//	Decrements the indentation level for all subsequent `Write`s and `Writeln`s.
+func (me *Buffer) Dedent() {
+	if me.indent > 0 {
+		me.indent--
+	}
+}
+
+//	Returns the current indentation level, as changed by `Indent` and `Dedent`.
+func (me *Buffer) IndentLevel() int {
+	return me.indent
+}
+
+//	Writes `open` as a line (unless empty), calls `body` one indentation level deeper,
+//	then writes `close` as a line (unless empty), such as `Block("switch x {", "}", body)`.
+func (me *Buffer) Block(open string, close string, body func()) {
+	if len(open) > 0 {
+		me.Writeln(open)
+	}
+	me.Indent()
+	body()
+	me.Dedent()
+	if len(close) > 0 {
+		me.Writeln(close)
+	}
+}
+
+//	Short-hand for `Block(header+" {", "}", body)`, or `Block("{", "}", body)` if `header` is empty.
+func (me *Buffer) Braced(header string, body func()) {
+	if len(header) > 0 {
+		header += " "
+	}
+	me.Block(header+"{", "}", body)
+}
+
+//	Returns the 1-based number of the line currently being written.
+func (me *Buffer) Line() int {
+	data := me.Bytes()
+	if me.counted > len(data) { // shrunk other than via `Reset` or `Truncate`, such as by reading
+		me.counted, me.numLines = 0, 0
+	}
+	me.numLines += bytes.Count(data[me.counted:], []byte{'\n'})
+	me.counted = len(data)
+	return me.numLines + 1
+}
+
+//	Empties `me` like `bytes.Buffer.Reset`, and also its `SrcMap` (but keeps `Indent` and `PushSrc` levels).
+func (me *Buffer) Reset() {
+	me.Buffer.Reset()
+	me.counted, me.numLines, me.srcLines = 0, 0, nil
+}
+
+//	Discards all but the first `n` bytes like `bytes.Buffer.Truncate`, and the `SrcMap` entries of the lines discarded.
+func (me *Buffer) Truncate(n int) {
+	me.Buffer.Truncate(n)
+	if n < me.counted {
+		me.counted, me.numLines = 0, 0
+	}
+	keep := me.Line()
+	if me.atLineStart() {
+		keep--
+	}
+	if len(me.srcLines) > keep {
+		me.srcLines = me.srcLines[:keep]
+	}
+}
+
+//	Makes `src` (such as an input AST node or its source span) the origin of all lines
+//	written from now on until the corresponding `PopSrc`, as reported by `SrcMap`.
+func (me *Buffer) PushSrc(src interface{}) {
+	me.srcs = append(me.srcs, src)
+}
+
+//	Ends the most recent `PushSrc`, restoring the one before it (if any).
+func (me *Buffer) PopSrc() {
+	if len(me.srcs) > 0 {
+		me.srcs = me.srcs[:len(me.srcs)-1]
+	}
+}
+
+//	Returns the source map: one entry per output line (index 0 for line 1) holding whichever `src` was
+//	current (as per `PushSrc`) when non-whitespace was first written to that line, or `nil` if none was.
+func (me *Buffer) SrcMap() (srcs []interface{}) {
+	srcs = make([]interface{}, me.Line())
+	copy(srcs, me.srcLines)
+	return
+}
+
+//	Replaces the contents of `me` with their `gofmt`ed form (via `go/format.Source`).
+//	The `SrcMap` is carried over as well as possible, by following lines that only changed in
+//	their white-space. If formatting fails, the contents remain unchanged and the `error` is returned.
+func (me *Buffer) GoFmt() (err error) {
+	var src []byte
+	if src, err = format.Source(me.Bytes()); err == nil {
+		srclines := me.SrcMap()
+		oldlines, newlines := strings.Split(me.String(), "\n"), strings.Split(string(src), "\n")
+		me.srcLines = make([]interface{}, len(newlines))
+		for o, n := 0, 0; o < len(oldlines) && n < len(newlines); o++ {
+			if oldln := bufNoSpaces(oldlines[o]); len(oldln) > 0 {
+				for i := n; i < len(newlines); i++ {
+					if bufNoSpaces(newlines[i]) == oldln {
+						me.srcLines[i], n = srclines[o], i+1
+						break
+					}
+				}
+			}
+		}
+		me.Buffer.Reset()
+		me.counted, me.numLines = 0, 0
+		me.Buffer.Write(src)
+	}
+	return
+}
+
+func bufNoSpaces(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
 }
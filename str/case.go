@@ -0,0 +1,134 @@
+package ustr
+
+import (
+	"strings"
+	"unicode"
+)
+
+//	The initialisms that `ToGoCamelCase` and `ToGoPascalCase` write fully upper-cased (as golint
+//	demands), keyed by their upper-case form. Add or remove entries to taste.
+var GoInitialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true, "GUID": true,
+	"HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true, "LHS": true, "QPS": true,
+	"RAM": true, "RHS": true, "RPC": true, "SLA": true, "SMTP": true, "SQL": true, "SSH": true, "TCP": true,
+	"TLS": true, "TTL": true, "UDP": true, "UI": true, "UID": true, "UUID": true, "URI": true, "URL": true,
+	"UTF8": true, "VM": true, "XML": true, "XMPP": true, "XSRF": true, "XSS": true,
+}
+
+//	Splits `s` into words: any runes other than letters and digits separate words (and are dropped),
+//	and so do case changes: `"HTTPServer2Go_fooBar-baz URLs"` becomes
+//	`["HTTP", "Server2", "Go", "foo", "Bar", "baz", "URLs"]`. That is:
+//
+//	- a lower-case letter or digit followed by an upper-case letter begins a new word (`fooBar`, `2Go`),
+//	- a run of upper-case letters followed by a lower-case letter is an acronym ending before its last
+//	upper-case letter (`HTTPServer`), unless that lower-case letter is a lone plural `s` (`URLs`, `IDsOf`),
+//	- digits belong to the word they follow (`Server2`, `utf8`), and a letter after digits begins a
+//	new word (`v2beta`), again unless it is a lone plural `s` (`MP3s`).
+func Words(s string) (words []string) {
+	runes, start := []rune(s), -1
+	flush := func(end int) {
+		if start >= 0 && end > start {
+			words = append(words, string(runes[start:end]))
+		}
+		start = -1
+	}
+	for i, r := range runes {
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			flush(i)
+			continue
+		} else if start < 0 {
+			start = i
+			continue
+		}
+		prev, isupper := runes[i-1], unicode.IsUpper(r)
+		if (isupper && (unicode.IsLower(prev) || unicode.IsDigit(prev))) ||
+			(isupper && unicode.IsUpper(prev) && i < len(runes)-1 && unicode.IsLower(runes[i+1]) && !caseIsPluralS(runes, i+1)) ||
+			(unicode.IsLower(r) && unicode.IsDigit(prev) && !caseIsPluralS(runes, i)) {
+			flush(i)
+			start = i
+		}
+	}
+	flush(len(runes))
+	return
+}
+
+func caseIsPluralS(runes []rune, i int) bool {
+	return runes[i] == 's' && (i == len(runes)-1 || !unicode.IsLower(runes[i+1]))
+}
+
+//	Returns `word` with its first rune upper-cased and all others lower-cased.
+func caseTitle(word string) string {
+	for i, r := range word {
+		return string(unicode.ToUpper(r)) + strings.ToLower(word[i+len(string(r)):])
+	}
+	return word
+}
+
+func caseJoin(s string, sep string, first func(string) string, rest func(string) string) string {
+	words := Words(s)
+	for i := range words {
+		if i == 0 {
+			words[i] = first(words[i])
+		} else {
+			words[i] = rest(words[i])
+		}
+	}
+	return strings.Join(words, sep)
+}
+
+//	Returns the `Words` of `s` in `camelCase`: `"HTTP server"` becomes `httpServer`.
+func ToCamelCase(s string) string {
+	return caseJoin(s, "", strings.ToLower, caseTitle)
+}
+
+//	Returns the `Words` of `s` in `PascalCase`: `"HTTP server"` becomes `HttpServer`.
+func ToPascalCase(s string) string {
+	return caseJoin(s, "", caseTitle, caseTitle)
+}
+
+//	Returns the `Words` of `s` in `snake_case`: `"HTTPServer"` becomes `http_server`.
+func ToSnakeCase(s string) string {
+	return caseJoin(s, "_", strings.ToLower, strings.ToLower)
+}
+
+//	Returns the `Words` of `s` in `kebab-case`: `"HTTPServer"` becomes `http-server`.
+func ToKebabCase(s string) string {
+	return caseJoin(s, "-", strings.ToLower, strings.ToLower)
+}
+
+//	Returns the `Words` of `s` in `SCREAMING_CASE`: `"httpServer"` becomes `HTTP_SERVER`.
+func ToScreamingCase(s string) string {
+	return caseJoin(s, "_", strings.ToUpper, strings.ToUpper)
+}
+
+//	Returns the `Words` of `s` in `Title Case`, but leaves acronyms alone:
+//	`"HTTP_server_port"` becomes `HTTP Server Port`.
+func ToTitleCase(s string) string {
+	title := func(word string) string {
+		if len(word) > 1 && IsUpper(word) {
+			return word
+		}
+		return caseTitle(word)
+	}
+	return caseJoin(s, " ", title, title)
+}
+
+//	Like `ToCamelCase`, but with `GoInitialisms` upper-cased (other than at the beginning,
+//	where they're lower-cased): `"user_id_url"` becomes `userIDURL`, `"IDs"` becomes `ids`.
+func ToGoCamelCase(s string) string {
+	return caseJoin(s, "", strings.ToLower, caseGoWord)
+}
+
+//	Like `ToPascalCase`, but with `GoInitialisms` upper-cased: `"http_server_ids"` becomes `HTTPServerIDs`.
+func ToGoPascalCase(s string) string {
+	return caseJoin(s, "", caseGoWord, caseGoWord)
+}
+
+func caseGoWord(word string) string {
+	if up := strings.ToUpper(word); GoInitialisms[up] {
+		return up
+	} else if len(word) > 2 && (word[len(word)-1] == 's') && GoInitialisms[up[:len(up)-1]] {
+		return up[:len(up)-1] + "s"
+	}
+	return caseTitle(word)
+}
@@ -0,0 +1,466 @@
+package ustr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//	The kinds of `DiffOp`s.
+type DiffKind int
+
+const (
+	//	`a[A1:A2]` equals `b[B1:B2]`.
+	DiffEqual DiffKind = iota
+
+	//	`a[A1:A2]` is removed (and `B1 == B2`).
+	DiffDelete
+
+	//	`b[B1:B2]` is inserted (and `A1 == A2`).
+	DiffInsert
+)
+
+//	One run of a diff turning `a` into `b`. For line diffs, the ranges are 0-based line indices
+//	(into `LinesKeepEols(a)` and `LinesKeepEols(b)`), for `DiffChars` they are byte offsets.
+//	Between two `DiffEqual` runs, there is at most one `DiffDelete` followed by at most one `DiffInsert`.
+type DiffOp struct {
+	Kind   DiffKind
+	A1, A2 int
+	B1, B2 int
+}
+
+//	Splits `s` into its lines, each (other than possibly the last one) keeping its terminating `\n`,
+//	so that `strings.Join(LinesKeepEols(s), "") == s`. An empty `s` has no lines.
+func LinesKeepEols(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+//	Returns the line diff turning `a` into `b`, via Myers' algorithm (as in `diff` and `git diff`).
+func DiffLines(a string, b string) []DiffOp {
+	return DiffStrs(LinesKeepEols(a), LinesKeepEols(b), false)
+}
+
+//	Like `DiffLines`, but via the patience algorithm (as in `git diff --patience`), which anchors the diff
+//	on lines occurring exactly once in both `a` and `b`. Often more readable for moved or re-ordered code.
+func DiffLinesPatience(a string, b string) []DiffOp {
+	return DiffStrs(LinesKeepEols(a), LinesKeepEols(b), true)
+}
+
+//	Returns the diff turning `a` into `b`, via either the patience or else Myers' algorithm.
+func DiffStrs(a []string, b []string, patience bool) []DiffOp {
+	ids := make(map[string]int, len(a))
+	tokenize := func(strs []string) (toks []int) {
+		toks = make([]int, len(strs))
+		for i, s := range strs {
+			id, ok := ids[s]
+			if !ok {
+				id = len(ids)
+				ids[s] = id
+			}
+			toks[i] = id
+		}
+		return
+	}
+	atoks, btoks := tokenize(a), tokenize(b)
+	if patience {
+		return diffNormalize(diffPatience(nil, atoks, btoks, 0, 0))
+	}
+	return diffNormalize(diffMyers(nil, atoks, btoks, 0, 0))
+}
+
+//	Returns the character-level diff turning `a` into `b`, computed over `rune`s but with
+//	the `DiffOp` ranges being byte offsets (always on `rune` boundaries) into `a` and `b`.
+func DiffChars(a string, b string) []DiffOp {
+	tokenize := func(s string) (toks []int, offsets []int) {
+		for i, r := range s {
+			toks, offsets = append(toks, int(r)), append(offsets, i)
+		}
+		return toks, append(offsets, len(s))
+	}
+	atoks, aoffsets := tokenize(a)
+	btoks, boffsets := tokenize(b)
+	ops := diffNormalize(diffMyers(nil, atoks, btoks, 0, 0))
+	for i := range ops {
+		op := &ops[i]
+		op.A1, op.A2, op.B1, op.B2 = aoffsets[op.A1], aoffsets[op.A2], boffsets[op.B1], boffsets[op.B2]
+	}
+	return ops
+}
+
+func diffAdd(ops []DiffOp, kind DiffKind, a1 int, a2 int, b1 int, b2 int) []DiffOp {
+	if a1 == a2 && b1 == b2 {
+		return ops
+	} else if l := len(ops) - 1; l >= 0 && ops[l].Kind == kind && ops[l].A2 == a1 && ops[l].B2 == b1 {
+		ops[l].A2, ops[l].B2 = a2, b2
+		return ops
+	}
+	return append(ops, DiffOp{Kind: kind, A1: a1, A2: a2, B1: b1, B2: b2})
+}
+
+//	Merges all deletions and insertions between two `DiffEqual` runs into one `DiffDelete` followed by one `DiffInsert`.
+func diffNormalize(ops []DiffOp) (normalized []DiffOp) {
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == DiffEqual {
+			normalized = diffAdd(normalized, DiffEqual, ops[i].A1, ops[i].A2, ops[i].B1, ops[i].B2)
+			i++
+			continue
+		}
+		a1, a2, b1, b2 := ops[i].A1, ops[i].A2, ops[i].B1, ops[i].B2
+		for i++; i < len(ops) && ops[i].Kind != DiffEqual; i++ {
+			a2, b2 = ops[i].A2, ops[i].B2
+		}
+		normalized = diffAdd(normalized, DiffDelete, a1, a2, b1, b1)
+		normalized = diffAdd(normalized, DiffInsert, a2, a2, b1, b2)
+	}
+	return
+}
+
+//	Appends to `ops` the diff of `a` and `b`, whose indices are offset by `a0` and `b0` in the `DiffOp`s.
+func diffMyers(ops []DiffOp, a []int, b []int, a0 int, b0 int) []DiffOp {
+	var pre, suf int
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ops = diffAdd(ops, DiffEqual, a0, a0+pre, b0, b0+pre)
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+	a0, b0 = a0+pre, b0+pre
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		ops = diffAdd(ops, DiffDelete, a0, a0+n, b0, b0)
+		ops = diffAdd(ops, DiffInsert, a0+n, a0+n, b0, b0+m)
+		return diffAdd(ops, DiffEqual, a0+n, a0+n+suf, b0+m, b0+m+suf)
+	}
+
+	// the classic forward search, keeping per `d` the `v` window needed for backtracking
+	off := n + m + 1
+	v, trace := make([]int, 2*off+1), [][]int(nil)
+	var d int
+search:
+	for d = 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[off-d-1:off+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			if v[off+k] = x; x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// backtracking yields the edit script in reverse
+	type step struct {
+		kind DiffKind
+		x, y int
+	}
+	var steps []step
+	x, y := n, m
+	for ; d >= 0; d-- {
+		vd, k := trace[d], x-y
+		at := func(k int) int { return vd[k+d+1] }
+		prevk := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevk = k + 1
+		}
+		prevx := at(prevk)
+		prevy := prevx - prevk
+		for x > prevx && y > prevy {
+			x, y = x-1, y-1
+			steps = append(steps, step{DiffEqual, x, y})
+		}
+		if d > 0 {
+			if x == prevx {
+				steps = append(steps, step{DiffInsert, x, prevy})
+			} else {
+				steps = append(steps, step{DiffDelete, prevx, y})
+			}
+		}
+		x, y = prevx, prevy
+	}
+	for i := len(steps) - 1; i >= 0; i-- {
+		switch s := steps[i]; s.kind {
+		case DiffEqual:
+			ops = diffAdd(ops, DiffEqual, a0+s.x, a0+s.x+1, b0+s.y, b0+s.y+1)
+		case DiffDelete:
+			ops = diffAdd(ops, DiffDelete, a0+s.x, a0+s.x+1, b0+s.y, b0+s.y)
+		case DiffInsert:
+			ops = diffAdd(ops, DiffInsert, a0+s.x, a0+s.x, b0+s.y, b0+s.y+1)
+		}
+	}
+	return diffAdd(ops, DiffEqual, a0+n, a0+n+suf, b0+m, b0+m+suf)
+}
+
+//	Like `diffMyers`, but anchored on the longest increasing sequence of the items unique in both `a` and `b`.
+func diffPatience(ops []DiffOp, a []int, b []int, a0 int, b0 int) []DiffOp {
+	type occ struct{ numa, numb, ia, ib int }
+	occs := map[int]*occ{}
+	for i, tok := range a {
+		if o := occs[tok]; o != nil {
+			o.numa++
+		} else {
+			occs[tok] = &occ{numa: 1, ia: i}
+		}
+	}
+	for i, tok := range b {
+		if o := occs[tok]; o != nil {
+			o.numb, o.ib = o.numb+1, i
+		}
+	}
+	// the unique pairs in `a` order, of which we want the longest run increasing in `b` order (via patience sorting)
+	var piles []int      // per pile: index (into `uniques`) of its top card
+	var backrefs []int   // per `uniques` index: index of the top card of the previous pile when it was placed
+	var uniques [][2]int // `ia`, `ib` pairs
+	for i, tok := range a {
+		if o := occs[tok]; o.numa == 1 && o.numb == 1 {
+			uniques = append(uniques, [2]int{i, o.ib})
+			u, p := len(uniques)-1, 0
+			for lo, hi := 0, len(piles); lo < hi; {
+				if mid := (lo + hi) / 2; uniques[piles[mid]][1] < o.ib {
+					lo, p = mid+1, mid+1
+				} else {
+					hi = mid
+				}
+			}
+			if backrefs = append(backrefs, -1); p > 0 {
+				backrefs[u] = piles[p-1]
+			}
+			if p == len(piles) {
+				piles = append(piles, u)
+			} else {
+				piles[p] = u
+			}
+		}
+	}
+	if len(piles) == 0 {
+		return diffMyers(ops, a, b, a0, b0)
+	}
+	var anchors [][2]int
+	for u := piles[len(piles)-1]; u >= 0; u = backrefs[u] {
+		anchors = append([][2]int{uniques[u]}, anchors...)
+	}
+	prevA, prevB := 0, 0
+	for _, anchor := range anchors {
+		ops = diffPatience(ops, a[prevA:anchor[0]], b[prevB:anchor[1]], a0+prevA, b0+prevB)
+		ops = diffAdd(ops, DiffEqual, a0+anchor[0], a0+anchor[0]+1, b0+anchor[1], b0+anchor[1]+1)
+		prevA, prevB = anchor[0]+1, anchor[1]+1
+	}
+	return diffPatience(ops, a[prevA:], b[prevB:], a0+prevA, b0+prevB)
+}
+
+//	One file's worth of a unified diff (as produced by `diff -u`, `git diff` or `gorename -d`).
+type UnifiedDiff struct {
+	//	The paths following the `---` and `+++` markers (without any tab-separated time-stamps).
+	OldPath, NewPath string
+
+	Hunks []UnifiedDiffHunk
+}
+
+//	One `@@ -OldLn,OldNum +NewLn,NewNum @@` hunk of a `UnifiedDiff`.
+type UnifiedDiffHunk struct {
+	//	1-based line numbers and line counts, as in the hunk header: for a `0` count,
+	//	the line number is that of the line preceding the hunk (`0` at the beginning of the file).
+	OldLn, OldNum, NewLn, NewNum int
+
+	//	Each line begins with its marker (` `, `-` or `+`) and ends in its original `\n` (or `\r\n`),
+	//	except for a last line that had none (denoted in the diff by a `\ No newline at end of file` line).
+	Lines []string
+}
+
+var diffHunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+//	Returns the `UnifiedDiff` turning text `a` into text `b` (`nil` if they're equal) with `numContext`
+//	lines of context around changes (`diff -u` uses 3), based on the `DiffOp`s from `DiffLines`.
+func NewUnifiedDiff(oldPath string, newPath string, a string, b string, numContext int) *UnifiedDiff {
+	return NewUnifiedDiffFrom(oldPath, newPath, a, b, DiffLines(a, b), numContext)
+}
+
+//	Like `NewUnifiedDiff`, but for the line-diff `ops` (from `DiffLines` or `DiffLinesPatience`) turning `a` into `b`.
+func NewUnifiedDiffFrom(oldPath string, newPath string, a string, b string, ops []DiffOp, numContext int) (me *UnifiedDiff) {
+	if numContext < 0 {
+		numContext = 0
+	}
+	alines, blines := LinesKeepEols(a), LinesKeepEols(b)
+	addLines := func(hunk *UnifiedDiffHunk, marker string, lines []string) {
+		for _, ln := range lines {
+			hunk.Lines = append(hunk.Lines, marker+ln)
+		}
+	}
+	for i := 0; i < len(ops); i++ {
+		if ops[i].Kind == DiffEqual {
+			continue
+		}
+		if me == nil {
+			me = &UnifiedDiff{OldPath: oldPath, NewPath: newPath}
+		}
+		numctx := 0
+		if i > 0 && ops[i-1].Kind == DiffEqual {
+			if numctx = ops[i-1].A2 - ops[i-1].A1; numctx > numContext {
+				numctx = numContext
+			}
+		}
+		hunk := UnifiedDiffHunk{OldLn: ops[i].A1 - numctx, NewLn: ops[i].B1 - numctx}
+		addLines(&hunk, " ", alines[hunk.OldLn:ops[i].A1])
+		for ; i < len(ops); i++ {
+			op := ops[i]
+			if op.Kind == DiffDelete {
+				addLines(&hunk, "-", alines[op.A1:op.A2])
+			} else if op.Kind == DiffInsert {
+				addLines(&hunk, "+", blines[op.B1:op.B2])
+			} else if num := op.A2 - op.A1; i == len(ops)-1 || num > 2*numContext {
+				if num > numContext {
+					num = numContext
+				}
+				addLines(&hunk, " ", alines[op.A1:op.A1+num])
+				break
+			} else {
+				addLines(&hunk, " ", alines[op.A1:op.A2])
+			}
+		}
+		for _, ln := range hunk.Lines {
+			if ln[0] != '+' {
+				hunk.OldNum++
+			}
+			if ln[0] != '-' {
+				hunk.NewNum++
+			}
+		}
+		if hunk.OldNum > 0 {
+			hunk.OldLn++
+		}
+		if hunk.NewNum > 0 {
+			hunk.NewLn++
+		}
+		me.Hunks = append(me.Hunks, hunk)
+	}
+	return
+}
+
+//	Returns the unified-diff text form of `me`, as understood by `patch` and `git apply`.
+func (me *UnifiedDiff) String() string {
+	var buf strings.Builder
+	rng := func(ln int, num int) string {
+		if num == 1 {
+			return strconv.Itoa(ln)
+		}
+		return strconv.Itoa(ln) + "," + strconv.Itoa(num)
+	}
+	buf.WriteString("--- " + me.OldPath + "\n+++ " + me.NewPath + "\n")
+	for _, hunk := range me.Hunks {
+		buf.WriteString("@@ -" + rng(hunk.OldLn, hunk.OldNum) + " +" + rng(hunk.NewLn, hunk.NewNum) + " @@\n")
+		for _, ln := range hunk.Lines {
+			if buf.WriteString(ln); !strings.HasSuffix(ln, "\n") {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return buf.String()
+}
+
+//	Parses all file diffs in the unified-diff text `src`. Lines outside of
+//	file headers and hunks (such as `git diff`'s `diff --git` and `index` lines) are skipped.
+func ParseUnifiedDiff(src string) (diffs []*UnifiedDiff, err error) {
+	lines := LinesKeepEols(src)
+	path := func(ln string) string {
+		if i := strings.IndexByte(ln, '\t'); i >= 0 {
+			ln = ln[:i]
+		}
+		return strings.TrimRight(ln, "\r\n")
+	}
+	for i := 0; i < len(lines); i++ {
+		ln := lines[i]
+		if strings.HasPrefix(ln, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			diffs = append(diffs, &UnifiedDiff{OldPath: path(ln[4:]), NewPath: path(lines[i+1][4:])})
+			i++
+			continue
+		} else if !strings.HasPrefix(ln, "@@ -") {
+			continue
+		} else if len(diffs) == 0 {
+			return nil, fmt.Errorf("line %d: hunk without preceding `---` and `+++` file header", i+1)
+		}
+		m := diffHunkHeader.FindStringSubmatch(ln)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: invalid hunk header: %s", i+1, strings.TrimSpace(ln))
+		}
+		hunk := UnifiedDiffHunk{OldLn: ToInt(m[1]), OldNum: 1, NewLn: ToInt(m[3]), NewNum: 1}
+		if len(m[2]) > 0 {
+			hunk.OldNum = ToInt(m[2])
+		}
+		if len(m[4]) > 0 {
+			hunk.NewNum = ToInt(m[4])
+		}
+		for numold, numnew := hunk.OldNum, hunk.NewNum; numold > 0 || numnew > 0 || (i+1 < len(lines) && strings.HasPrefix(lines[i+1], "\\")); {
+			if i++; i >= len(lines) {
+				return nil, fmt.Errorf("line %d: hunk ends prematurely", i)
+			}
+			switch ln = lines[i]; {
+			case ln == "\n" || ln == "\r\n": // an empty context line whose space was stripped by some editor or mailer
+				ln = " " + ln
+				fallthrough
+			case ln[0] == ' ':
+				numold, numnew = numold-1, numnew-1
+			case ln[0] == '-':
+				numold--
+			case ln[0] == '+':
+				numnew--
+			case ln[0] == '\\':
+				if l := len(hunk.Lines) - 1; l >= 0 {
+					hunk.Lines[l] = strings.TrimSuffix(hunk.Lines[l], "\n")
+				}
+				continue
+			default:
+				return nil, fmt.Errorf("line %d: unexpected line in hunk: %s", i+1, strings.TrimSpace(ln))
+			}
+			if numold < 0 || numnew < 0 {
+				return nil, fmt.Errorf("line %d: hunk has more lines than its header states", i+1)
+			}
+			hunk.Lines = append(hunk.Lines, ln)
+		}
+		diff := diffs[len(diffs)-1]
+		diff.Hunks = append(diff.Hunks, hunk)
+	}
+	return
+}
+
+//	Applies `me` to the text `a`, returning the resulting text. All context and removed lines must match `a`
+//	exactly at the hunks' stated positions (no fuzzy offsetting as by `patch`), else an error is returned.
+func (me *UnifiedDiff) Apply(a string) (string, error) {
+	var buf strings.Builder
+	alines, pos := LinesKeepEols(a), 0
+	for h, hunk := range me.Hunks {
+		start := hunk.OldLn - 1
+		if hunk.OldNum == 0 {
+			start = hunk.OldLn
+		}
+		if start < pos || start > len(alines) {
+			return "", fmt.Errorf("%s: hunk %d (@@ -%d,%d) out of order or range", me.OldPath, h+1, hunk.OldLn, hunk.OldNum)
+		}
+		buf.WriteString(strings.Join(alines[pos:start], ""))
+		pos = start
+		for _, ln := range hunk.Lines {
+			if ln[0] == '+' {
+				buf.WriteString(ln[1:])
+			} else if pos >= len(alines) || alines[pos] != ln[1:] {
+				return "", fmt.Errorf("%s: hunk %d (@@ -%d,%d) does not match line %d", me.OldPath, h+1, hunk.OldLn, hunk.OldNum, pos+1)
+			} else if pos++; ln[0] == ' ' {
+				buf.WriteString(ln[1:])
+			}
+		}
+	}
+	buf.WriteString(strings.Join(alines[pos:], ""))
+	return buf.String(), nil
+}
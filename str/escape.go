@@ -0,0 +1,584 @@
+package ustr
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+//	The matched quote/unquote (or escape/unescape) pairs in this file guarantee
+//	`Unquote(Quote(s)) == s` for all valid-UTF-8 `s` --- and for all `s` whatsoever
+//	in the case of Go, shell and XML, which can carry arbitrary bytes.
+
+func escErr(lang string, src string, pos int, msg string) error {
+	return fmt.Errorf("invalid %s string literal at offset %d (%s): %s", lang, pos, msg, escShorten(src, 64))
+}
+
+func escShorten(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	for maxLen > 0 && !utf8.RuneStart(s[maxLen]) {
+		maxLen--
+	}
+	return s[:maxLen] + "…"
+}
+
+//	Returns a double-quoted Go string literal representing `s` (via `strconv.Quote`).
+func QuoteGo(s string) string {
+	return strconv.Quote(s)
+}
+
+//	Returns the string value of the specified Go string (or rune) literal,
+//	double-quoted, back-quoted or single-quoted (via `strconv.Unquote`).
+func UnquoteGo(s string) (string, error) {
+	return strconv.Unquote(s)
+}
+
+//	Returns a double-quoted JSON string representing `s`. Only `"`, `\` and control characters are
+//	escaped, plus U+2028 and U+2029 (so that the result is also a valid JS string literal).
+//	Invalid UTF-8 byte sequences are written as U+FFFD, as JSON can't represent them.
+func QuoteJson(s string) string {
+	return jsQuote(s, '"')
+}
+
+//	Returns the string value of the specified double-quoted JSON string literal, with strict JSON syntax.
+func UnquoteJson(s string) (string, error) {
+	return jsUnquote(s, true)
+}
+
+//	Returns a JS string literal representing `s` in the specified `quote`s, which may be `"`, `'`
+//	or a back-tick (for a template literal, with `${` escaped). Escaping is as for `QuoteJson`.
+func QuoteJs(s string, quote byte) string {
+	return jsQuote(s, quote)
+}
+
+//	Returns the string value of the specified JS string literal: single-quoted, double-quoted or a back-tick
+//	template literal without `${` substitutions. All JS escapes are supported, including `\xHH`,
+//	`\u{H...}`, surrogate pairs and line continuations.
+func UnquoteJs(s string) (string, error) {
+	return jsUnquote(s, false)
+}
+
+func jsQuote(s string, quote byte) string {
+	var buf strings.Builder
+	buf.Grow(len(s) + 2)
+	buf.WriteByte(quote)
+	for i, r := range s {
+		switch {
+		case r == rune(quote) || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r == '\b':
+			buf.WriteString(`\b`)
+		case r == '\f':
+			buf.WriteString(`\f`)
+		case r < 0x20 || r == 0x2028 || r == 0x2029:
+			buf.WriteString(`\u`)
+			buf.WriteString(fmt.Sprintf("%04x", r))
+		case r == '$' && quote == '`' && i < len(s)-1 && s[i+1] == '{':
+			buf.WriteString(`\$`)
+		default:
+			buf.WriteRune(r) // also writes U+FFFD for invalid UTF-8
+		}
+	}
+	buf.WriteByte(quote)
+	return buf.String()
+}
+
+func jsUnquote(s string, isJson bool) (string, error) {
+	lang := "JS"
+	if isJson {
+		lang = "JSON"
+	}
+	if len(s) < 2 || s[0] != s[len(s)-1] || (s[0] != '"' && (isJson || (s[0] != '\'' && s[0] != '`'))) {
+		return "", escErr(lang, s, 0, "not quoted")
+	}
+	quote, body := s[0], s[1:len(s)-1]
+	if strings.IndexFunc(body, func(r rune) bool { return r < 0x20 || r == '\\' || r == rune(quote) || r == '$' }) < 0 {
+		return body, nil
+	}
+	var buf strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == quote:
+			return "", escErr(lang, s, i+1, "unescaped quote")
+		case c == '$' && quote == '`' && i < len(body)-1 && body[i+1] == '{':
+			return "", escErr(lang, s, i+1, "template substitution")
+		case c < 0x20 && (isJson || (quote != '`' && (c == '\n' || c == '\r'))):
+			return "", escErr(lang, s, i+1, "unescaped control character")
+		case c != '\\':
+			buf.WriteByte(c)
+			continue
+		}
+		if i++; i >= len(body) {
+			return "", escErr(lang, s, i, "incomplete escape")
+		}
+		switch c = body[i]; c {
+		case '"', '\\', '/':
+			buf.WriteByte(c)
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case 'b':
+			buf.WriteByte('\b')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'u':
+			var r rune
+			if !isJson && i < len(body)-1 && body[i+1] == '{' {
+				end := strings.IndexByte(body[i:], '}')
+				if end < 0 {
+					return "", escErr(lang, s, i, "unterminated \\u{...} escape")
+				}
+				n, err := strconv.ParseUint(body[i+2:i+end], 16, 32)
+				if err != nil || n > unicode.MaxRune {
+					return "", escErr(lang, s, i, "invalid \\u{...} escape")
+				}
+				r, i = rune(n), i+end
+			} else {
+				n, err := jsHex4(body, i+1)
+				if err != nil {
+					return "", escErr(lang, s, i, err.Error())
+				}
+				r, i = n, i+4
+				if utf16.IsSurrogate(r) && i+6 < len(body) && body[i+1] == '\\' && body[i+2] == 'u' {
+					if r2, err := jsHex4(body, i+3); err == nil && utf16.DecodeRune(r, r2) != unicode.ReplacementChar {
+						r, i = utf16.DecodeRune(r, r2), i+6
+					}
+				}
+			}
+			buf.WriteRune(r)
+		default:
+			if isJson {
+				return "", escErr(lang, s, i, "unknown escape")
+			}
+			switch c {
+			case '\'', '`', '$':
+				buf.WriteByte(c)
+			case 'v':
+				buf.WriteByte('\v')
+			case '0':
+				if i < len(body)-1 && body[i+1] >= '0' && body[i+1] <= '9' {
+					return "", escErr(lang, s, i, "octal escape")
+				}
+				buf.WriteByte(0)
+			case 'x':
+				if i+3 > len(body) {
+					return "", escErr(lang, s, i, "incomplete \\x escape")
+				}
+				n, err := strconv.ParseUint(body[i+1:i+3], 16, 8)
+				if err != nil {
+					return "", escErr(lang, s, i, "invalid \\x escape")
+				}
+				buf.WriteRune(rune(n))
+				i += 2
+			case '\r': // line continuation
+				if i < len(body)-1 && body[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+			default:
+				if c >= 0x80 { // escaped non-ASCII rune stands for itself (except U+2028/U+2029 continuations)
+					r, size := utf8.DecodeRuneInString(body[i:])
+					if r != 0x2028 && r != 0x2029 {
+						buf.WriteRune(r)
+					}
+					i += size - 1
+				} else if c >= '1' && c <= '9' {
+					return "", escErr(lang, s, i, "octal escape")
+				} else {
+					buf.WriteByte(c)
+				}
+			}
+		}
+	}
+	return buf.String(), nil
+}
+
+func jsHex4(s string, i int) (rune, error) {
+	if i+4 > len(s) {
+		return 0, fmt.Errorf("incomplete \\u escape")
+	}
+	n, err := strconv.ParseUint(s[i:i+4], 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid \\u escape")
+	}
+	return rune(n), nil
+}
+
+//	The ASCII control character names usable in Haskell escapes such as `\NUL` or `\DEL`.
+var hsAsciiNames = []string{"NUL", "SOH", "STX", "ETX", "EOT", "ENQ", "ACK", "BEL", "BS", "HT", "LF", "VT", "FF", "CR", "SO", "SI",
+	"DLE", "DC1", "DC2", "DC3", "DC4", "NAK", "SYN", "ETB", "CAN", "EM", "SUB", "ESC", "FS", "GS", "RS", "US", "SP"}
+
+//	Returns a double-quoted Haskell string literal representing `s`: non-printable runes are
+//	written as decimal escapes (followed by `\&` if a digit follows), all others as-is.
+func QuoteHaskell(s string) string {
+	return hsQuote(s, false)
+}
+
+//	Returns a double-quoted PureScript string literal representing `s`: like `QuoteHaskell`, but
+//	non-printable runes are written as 6-digit `\x` escapes, as PureScript has no decimal escapes.
+func QuotePureScript(s string) string {
+	return hsQuote(s, true)
+}
+
+func hsQuote(s string, purs bool) string {
+	var buf strings.Builder
+	buf.Grow(len(s) + 2)
+	buf.WriteByte('"')
+	for i, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case unicode.IsPrint(r): // also writes U+FFFD for invalid UTF-8
+			buf.WriteRune(r)
+		case purs:
+			buf.WriteString(fmt.Sprintf(`\x%06x`, r))
+		default:
+			buf.WriteString(`\` + strconv.Itoa(int(r)))
+			if i+utf8.RuneLen(r) < len(s) && s[i+utf8.RuneLen(r)] >= '0' && s[i+utf8.RuneLen(r)] <= '9' {
+				buf.WriteString(`\&`)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+//	Returns the string value of the specified Haskell string literal, supporting all Haskell 2010
+//	escapes: character escapes, decimal, `\x` hex and `\o` octal escapes, ASCII control names
+//	such as `\NUL` and `\^A`, the empty escape `\&` and string gaps.
+func UnquoteHaskell(s string) (string, error) {
+	return hsUnquote(s, false)
+}
+
+//	Returns the string value of the specified PureScript string literal, supporting character escapes,
+//	`\x` hex escapes of 1 to 6 digits and string gaps. (Triple-quoted raw strings are returned as-is.)
+func UnquotePureScript(s string) (string, error) {
+	if len(s) >= 6 && strings.HasPrefix(s, `"""`) && strings.HasSuffix(s, `"""`) {
+		return s[3 : len(s)-3], nil
+	}
+	return hsUnquote(s, true)
+}
+
+func hsUnquote(s string, purs bool) (string, error) {
+	lang := "Haskell"
+	if purs {
+		lang = "PureScript"
+	}
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", escErr(lang, s, 0, "not quoted")
+	}
+	body := s[1 : len(s)-1]
+	var buf strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c == '"' || c == '\n' {
+			return "", escErr(lang, s, i+1, "unescaped quote or line break")
+		} else if c != '\\' {
+			buf.WriteByte(c)
+			continue
+		}
+		if i++; i >= len(body) {
+			return "", escErr(lang, s, i, "incomplete escape")
+		}
+		var r rune = -1
+		switch c = body[i]; c {
+		case '"', '\\', '\'':
+			r = rune(c)
+		case 'n':
+			r = '\n'
+		case 'r':
+			r = '\r'
+		case 't':
+			r = '\t'
+		case 'a', 'b', 'f', 'v', '&', 'o', '^':
+			if !purs {
+				r = hsUnescapeMore(body, &i)
+			}
+		case 'x':
+			end := i + 1
+			for end < len(body) && strings.IndexByte("0123456789abcdefABCDEF", body[end]) >= 0 && !(purs && end-i > 6) {
+				end++
+			}
+			if n, err := strconv.ParseUint(body[i+1:end], 16, 32); err == nil && n <= unicode.MaxRune {
+				r, i = rune(n), end-1
+			}
+		default:
+			if c >= '0' && c <= '9' && !purs {
+				end := i
+				for end < len(body) && body[end] >= '0' && body[end] <= '9' {
+					end++
+				}
+				if n, err := strconv.ParseUint(body[i:end], 10, 32); err == nil && n <= unicode.MaxRune {
+					r, i = rune(n), end-1
+				}
+			} else if c == ' ' || c == '\t' || c == '\n' || c == '\r' { // string gap
+				end := i
+				for end < len(body) && (body[end] == ' ' || body[end] == '\t' || body[end] == '\n' || body[end] == '\r') {
+					end++
+				}
+				if end < len(body) && body[end] == '\\' {
+					i = end
+					continue
+				}
+			} else if !purs {
+				r = hsUnescapeMore(body, &i)
+			}
+		}
+		if r == -2 { // `\&`
+			continue
+		}
+		if r < 0 {
+			return "", escErr(lang, s, i, "invalid escape")
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String(), nil
+}
+
+//	Handles the Haskell-only escapes starting at `body[*i]`, returning -1 if invalid or -2 for the empty `\&`.
+func hsUnescapeMore(body string, i *int) (r rune) {
+	r = -1
+	switch c := body[*i]; c {
+	case 'a':
+		r = '\a'
+	case 'b':
+		r = '\b'
+	case 'f':
+		r = '\f'
+	case 'v':
+		r = '\v'
+	case '&':
+		r = -2
+	case 'o':
+		end := *i + 1
+		for end < len(body) && body[end] >= '0' && body[end] <= '7' {
+			end++
+		}
+		if n, err := strconv.ParseUint(body[*i+1:end], 8, 32); err == nil && n <= unicode.MaxRune {
+			r, *i = rune(n), end-1
+		}
+	case '^':
+		if *i < len(body)-1 && body[*i+1] >= '@' && body[*i+1] <= '_' {
+			r, *i = rune(body[*i+1]-'@'), *i+1
+		}
+	default: // ASCII control names, longest match first (as in `\SOH` vs. `\SO`)
+		name := ""
+		for n, cname := range hsAsciiNames {
+			if len(cname) > len(name) && strings.HasPrefix(body[*i:], cname) {
+				name, r = cname, rune(n)
+			}
+		}
+		if strings.HasPrefix(body[*i:], "DEL") {
+			name, r = "DEL", 0x7F
+		}
+		if len(name) > 0 {
+			*i += len(name) - 1
+		}
+	}
+	return
+}
+
+//	Returns `s` quoted for POSIX shells: as-is if it consists only of letters, digits and any of
+//	`_@%+=:,./-` (and isn't empty), otherwise in single quotes (with each `'` written as `'\''`).
+func QuoteShell(s string) string {
+	if len(s) > 0 && strings.IndexFunc(s, func(r rune) bool {
+		return !(r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_@%+=:,./-", r)))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+//	Returns `s` in double quotes for POSIX shells, with `$`, back-tick, `"` and `\` escaped.
+//	(Note that interactive `bash` sessions may still perform `!` history expansion in double quotes.)
+func QuoteShellDouble(s string) string {
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == '$' || c == '`' || c == '"' || c == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(s[i])
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+//	Returns the value of the specified single POSIX shell word, as the shell would after quote removal:
+//	unquoted, `'`-single-quoted and `"`-double-quoted parts (such as `'a b'"c"\ d`) are concatenated.
+//	Unquoted white-space and any expansions (`$`, back-ticks, globs) result in an `error`.
+func UnquoteShell(s string) (string, error) {
+	word, rest, err := shellWord(s)
+	if err == nil && len(rest) > 0 {
+		err = escErr("shell", s, len(s)-len(rest), "unquoted white-space")
+	}
+	return word, err
+}
+
+//	Reads the first shell word from `s`, returning the `rest` following it.
+func shellWord(s string) (word string, rest string, err error) {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case ' ', '\t', '\n':
+			return buf.String(), s[i:], nil
+		case '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return "", "", escErr("shell", s, i, "unterminated single quote")
+			}
+			buf.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case '"':
+			for i++; ; i++ {
+				if i >= len(s) {
+					return "", "", escErr("shell", s, i, "unterminated double quote")
+				} else if c = s[i]; c == '"' {
+					break
+				} else if c == '$' || c == '`' {
+					return "", "", escErr("shell", s, i, "unsupported expansion")
+				} else if c == '\\' && i < len(s)-1 && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					if i++; s[i] != '\n' {
+						buf.WriteByte(s[i])
+					}
+				} else {
+					buf.WriteByte(c)
+				}
+			}
+		case '\\':
+			if i++; i >= len(s) {
+				return "", "", escErr("shell", s, i, "incomplete escape")
+			} else if s[i] != '\n' {
+				buf.WriteByte(s[i])
+			}
+		case '$', '`', '*', '?', '[', '|', '&', ';', '<', '>', '(', ')':
+			return "", "", escErr("shell", s, i, "unsupported expansion or operator")
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String(), "", nil
+}
+
+//	Escapes `&`, `<` and `>` for use of `s` as XML or HTML text content.
+func EscapeXmlText(s string) string {
+	return xmlEscaper(s, false)
+}
+
+//	Escapes `&`, `<`, `>`, `"`, `'`, tabs and line breaks (to keep them from being normalized
+//	to spaces) for use of `s` as a quoted XML or HTML attribute value.
+func EscapeXmlAttr(s string) string {
+	return xmlEscaper(s, true)
+}
+
+func xmlEscaper(s string, attr bool) string {
+	var buf strings.Builder
+	last := 0
+	for i := 0; i < len(s); i++ {
+		var esc string
+		switch c := s[i]; {
+		case c == '&':
+			esc = "&amp;"
+		case c == '<':
+			esc = "&lt;"
+		case c == '>':
+			esc = "&gt;"
+		case c == '"' && attr:
+			esc = "&quot;"
+		case c == '\'' && attr:
+			esc = "&apos;"
+		case c < 0x20 && (attr || (c != '\t' && c != '\n')):
+			esc = "&#" + strconv.Itoa(int(c)) + ";"
+		default:
+			continue
+		}
+		buf.WriteString(s[last:i])
+		buf.WriteString(esc)
+		last = i + 1
+	}
+	if last == 0 {
+		return s
+	}
+	buf.WriteString(s[last:])
+	return buf.String()
+}
+
+//	Returns the value of the specified XML text or attribute value (without its quotes), decoding the 5
+//	predefined entities and numeric character references. Any other `&` results in an `error`.
+func UnescapeXml(s string) (string, error) {
+	if strings.IndexByte(s, '&') < 0 {
+		return s, nil
+	}
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '&' {
+			buf.WriteByte(s[i])
+			continue
+		}
+		end := strings.IndexByte(s[i:], ';')
+		if end < 0 {
+			return "", escErr("XML", s, i, "unterminated entity")
+		}
+		switch entity := s[i+1 : i+end]; entity {
+		case "amp":
+			buf.WriteByte('&')
+		case "lt":
+			buf.WriteByte('<')
+		case "gt":
+			buf.WriteByte('>')
+		case "quot":
+			buf.WriteByte('"')
+		case "apos":
+			buf.WriteByte('\'')
+		default:
+			var n uint64
+			var err error = strconv.ErrSyntax
+			if strings.HasPrefix(entity, "#x") {
+				n, err = strconv.ParseUint(entity[2:], 16, 32)
+			} else if strings.HasPrefix(entity, "#") {
+				n, err = strconv.ParseUint(entity[1:], 10, 32)
+			}
+			if err != nil || n > unicode.MaxRune {
+				return "", escErr("XML", s, i, "unknown entity")
+			}
+			buf.WriteRune(rune(n))
+		}
+		i += end
+	}
+	return buf.String(), nil
+}
+
+//	Escapes `&`, `<`, `>`, `"` and `'` for use of `s` as HTML text or (quoted) attribute
+//	value (as per `html.EscapeString`). Unlike in XML, nothing else needs escaping in HTML.
+func EscapeHtml(s string) string {
+	return html.EscapeString(s)
+}
+
+//	Decodes all HTML entities in `s` (named and numeric, as per `html.UnescapeString`).
+func UnescapeHtml(s string) string {
+	return html.UnescapeString(s)
+}
@@ -0,0 +1,74 @@
+package ustr
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+var escapeFuzzSeeds = []string{"", "plain", "a b\tc\nd\re", `"'` + "`", `\`, "${x}", "$HOME `cmd`", "&amp; <a href='x'>",
+	"\x00\x01\x1f\x7f", "  ", "日本語 😀", "\xff\xfe", "\\&1", "\x0123", "*?[a] | & ; < > ( )", "'\\''"}
+
+func fuzzRoundTrip(f *testing.F, anyBytes bool, quote func(string) string, unquote func(string) (string, error)) {
+	for _, seed := range escapeFuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if !(anyBytes || utf8.ValidString(s)) {
+			return
+		}
+		quoted := quote(s)
+		if unquoted, err := unquote(quoted); err != nil {
+			t.Fatalf("%q quoted as %s: %v", s, quoted, err)
+		} else if unquoted != s {
+			t.Fatalf("%q quoted as %s unquoted as %q", s, quoted, unquoted)
+		}
+	})
+}
+
+func FuzzQuoteGo(f *testing.F) {
+	fuzzRoundTrip(f, true, QuoteGo, UnquoteGo)
+}
+
+func FuzzQuoteJson(f *testing.F) {
+	fuzzRoundTrip(f, false, QuoteJson, UnquoteJson)
+}
+
+func FuzzQuoteJsDouble(f *testing.F) {
+	fuzzRoundTrip(f, false, func(s string) string { return QuoteJs(s, '"') }, UnquoteJs)
+}
+
+func FuzzQuoteJsSingle(f *testing.F) {
+	fuzzRoundTrip(f, false, func(s string) string { return QuoteJs(s, '\'') }, UnquoteJs)
+}
+
+func FuzzQuoteJsTemplate(f *testing.F) {
+	fuzzRoundTrip(f, false, func(s string) string { return QuoteJs(s, '`') }, UnquoteJs)
+}
+
+func FuzzQuoteHaskell(f *testing.F) {
+	fuzzRoundTrip(f, false, QuoteHaskell, UnquoteHaskell)
+}
+
+func FuzzQuotePureScript(f *testing.F) {
+	fuzzRoundTrip(f, false, QuotePureScript, UnquotePureScript)
+}
+
+func FuzzQuoteShell(f *testing.F) {
+	fuzzRoundTrip(f, true, QuoteShell, UnquoteShell)
+}
+
+func FuzzQuoteShellDouble(f *testing.F) {
+	fuzzRoundTrip(f, true, QuoteShellDouble, UnquoteShell)
+}
+
+func FuzzEscapeXmlText(f *testing.F) {
+	fuzzRoundTrip(f, true, EscapeXmlText, UnescapeXml)
+}
+
+func FuzzEscapeXmlAttr(f *testing.F) {
+	fuzzRoundTrip(f, true, EscapeXmlAttr, UnescapeXml)
+}
+
+func FuzzEscapeHtml(f *testing.F) {
+	fuzzRoundTrip(f, false, EscapeHtml, func(s string) (string, error) { return UnescapeHtml(s), nil })
+}
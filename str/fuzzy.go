@@ -0,0 +1,193 @@
+package ustr
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+//	The scoring weights used by `FuzzyScore` and `FuzzyRank`, adjustable to taste.
+var (
+	FuzzyScoreMatch          = 16
+	FuzzyScoreGapStart       = -3
+	FuzzyScoreGapExtension   = -1
+	FuzzyBonusBoundary       = 8 // after a space, `_`, `-`, `.`, `:` or at the very beginning
+	FuzzyBonusPathSep        = 9 // after a `/` or `\`
+	FuzzyBonusCamel          = 7 // lower-to-upper-case or letter-to-digit transitions
+	FuzzyBonusConsecutive    = 4
+	FuzzyBonusFirstQueryRune = 2 // multiplies the boundary bonus at which the first query rune matches
+)
+
+//	A successful fuzzy match of a query against a `Candidate`, as returned by `FuzzyRank`.
+type FuzzyMatch struct {
+	//	The matched candidate string.
+	Candidate string
+
+	//	The position of `Candidate` in the `candidates` passed to `FuzzyRank`.
+	Index int
+
+	//	Higher is better. Only comparable between matches of the same query.
+	Score int
+
+	//	The byte offsets into `Candidate` of all runes matched by the query, for highlighting.
+	Positions []int
+}
+
+//	Implements `sort.Interface`: best `Score` first, then shorter `Candidate` first, then lower `Index` first.
+type FuzzyMatches []FuzzyMatch
+
+func (me FuzzyMatches) Len() int      { return len(me) }
+func (me FuzzyMatches) Swap(i, j int) { me[i], me[j] = me[j], me[i] }
+func (me FuzzyMatches) Less(i, j int) bool {
+	if me[i].Score != me[j].Score {
+		return me[i].Score > me[j].Score
+	} else if li, lj := len(me[i].Candidate), len(me[j].Candidate); li != lj {
+		return li < lj
+	}
+	return me[i].Index < me[j].Index
+}
+
+//	Reusable per-`FuzzyRank` buffers, so that scoring many candidates doesn't allocate per candidate.
+type fuzzyScorer struct {
+	query         []rune
+	caseSensitive bool
+	cand          []rune
+	candOffsets   []int
+	bonus         []int
+	score, from   []int // (len(query) * len(cand)) matrices: best score ending with query[i] at cand[j], and its predecessor j
+}
+
+func newFuzzyScorer(query string) *fuzzyScorer {
+	me := &fuzzyScorer{query: []rune(query)}
+	for _, r := range me.query {
+		if unicode.IsUpper(r) {
+			me.caseSensitive = true
+			break
+		}
+	}
+	return me
+}
+
+//	Scores `candidate` against `query` in the manner of fuzzy-finders such as fzf or Sublime Text's:
+//	all runes in `query` have to occur in `candidate` in order (but not necessarily consecutively), with
+//	the best-scoring such alignment chosen. Consecutive matches and those at word boundaries (camelCase
+//	humps, after path separators, `_`, `-`, `.` etc.) score higher, gaps lower.
+//
+//	Matching is case-insensitive, unless `query` contains upper-case letters ("smart case").
+//	`positions` are the byte offsets into `candidate` of all matched runes.
+//	An empty `query` matches everything with a `score` of 0.
+func FuzzyScore(query string, candidate string) (score int, positions []int, ok bool) {
+	return newFuzzyScorer(query).match(candidate)
+}
+
+//	Returns all `candidates` that `FuzzyScore` matches against `query`, best matches first (as per `FuzzyMatches`).
+func FuzzyRank(query string, candidates []string) (matches []FuzzyMatch) {
+	scorer := newFuzzyScorer(query)
+	for i, cand := range candidates {
+		if score, positions, ok := scorer.match(cand); ok {
+			matches = append(matches, FuzzyMatch{Candidate: cand, Index: i, Score: score, Positions: positions})
+		}
+	}
+	sort.Sort(FuzzyMatches(matches))
+	return
+}
+
+func (me *fuzzyScorer) eq(q rune, c rune) bool {
+	return q == c || ((!me.caseSensitive) && unicode.ToLower(c) == q)
+}
+
+func (me *fuzzyScorer) match(candidate string) (score int, positions []int, ok bool) {
+	nq := len(me.query)
+	if nq == 0 {
+		return 0, nil, true
+	}
+
+	//	quick reject before any real work: is `query` a subsequence at all?
+	i := 0
+	for _, r := range candidate {
+		if me.eq(me.query[i], r) {
+			if i++; i == nq {
+				break
+			}
+		}
+	}
+	if i < nq {
+		return
+	}
+
+	me.cand, me.candOffsets, me.bonus = me.cand[:0], me.candOffsets[:0], me.bonus[:0]
+	prev := utf8.RuneError
+	for offset, r := range candidate {
+		me.cand, me.candOffsets = append(me.cand, r), append(me.candOffsets, offset)
+		me.bonus = append(me.bonus, fuzzyBonus(prev, r, offset == 0))
+		prev = r
+	}
+	nc := len(me.cand)
+	if size := nq * nc; cap(me.score) < size {
+		me.score, me.from = make([]int, size), make([]int, size)
+	} else {
+		me.score, me.from = me.score[:size], me.from[:size]
+	}
+
+	const none = -1 << 30
+	for qi := 0; qi < nq; qi++ {
+		row, prevrow := me.score[qi*nc:(qi+1)*nc], []int(nil)
+		if qi > 0 {
+			prevrow = me.score[(qi-1)*nc : qi*nc]
+		}
+		gapbest, gapfrom := none, -1 // best predecessor with a gap, including gap penalties up to cj
+		for cj := 0; cj < nc; cj++ {
+			row[cj], me.from[qi*nc+cj] = none, -1
+			if qi > 0 && cj >= 2 {
+				if gapbest != none {
+					gapbest += FuzzyScoreGapExtension
+				}
+				if s := prevrow[cj-2]; s != none && s+FuzzyScoreGapStart > gapbest {
+					gapbest, gapfrom = s+FuzzyScoreGapStart, cj-2
+				}
+			}
+			if !me.eq(me.query[qi], me.cand[cj]) {
+				continue
+			}
+			if qi == 0 {
+				row[cj] = FuzzyScoreMatch + me.bonus[cj]*FuzzyBonusFirstQueryRune
+				continue
+			}
+			if cj > 0 && prevrow[cj-1] != none {
+				row[cj], me.from[qi*nc+cj] = prevrow[cj-1]+FuzzyScoreMatch+me.bonus[cj]+FuzzyBonusConsecutive, cj-1
+			}
+			if gapbest != none && gapbest+FuzzyScoreMatch+me.bonus[cj] > row[cj] {
+				row[cj], me.from[qi*nc+cj] = gapbest+FuzzyScoreMatch+me.bonus[cj], gapfrom
+			}
+		}
+	}
+
+	lastrow, best := me.score[(nq-1)*nc:], -1
+	for cj := range lastrow {
+		if lastrow[cj] != none && (best < 0 || lastrow[cj] > lastrow[best]) {
+			best = cj
+		}
+	}
+	if ok = best >= 0; ok {
+		score, positions = lastrow[best], make([]int, nq)
+		for qi, cj := nq-1, best; qi >= 0; qi-- {
+			positions[qi] = me.candOffsets[cj]
+			cj = me.from[qi*nc+cj]
+		}
+	}
+	return
+}
+
+func fuzzyBonus(prev rune, r rune, isFirst bool) int {
+	switch {
+	case isFirst:
+		return FuzzyBonusBoundary
+	case prev == '/' || prev == '\\':
+		return FuzzyBonusPathSep
+	case prev == ' ' || prev == '_' || prev == '-' || prev == '.' || prev == ':' || prev == '\t':
+		return FuzzyBonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(r), unicode.IsLetter(prev) && unicode.IsDigit(r):
+		return FuzzyBonusCamel
+	}
+	return 0
+}
@@ -1,7 +1,11 @@
 package ustr
 
 import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
 	"strings"
+	"sync"
 )
 
 //	Uses a `Matcher` to determine whether `value` matches any one of the specified simple-`patterns`.
@@ -13,7 +17,19 @@ func MatchesAny(value string, patterns ...string) bool {
 
 type matcherPattern struct {
 	pattern, prefix, suffix, contains string
-	any                               bool
+	any, negated                      bool
+	glob                              *regexp.Regexp
+}
+
+func (me *matcherPattern) isMatch(s string) bool {
+	if me.any || s == me.pattern {
+		return true
+	} else if me.glob != nil {
+		return me.glob.MatchString(s)
+	}
+	return (len(me.prefix) > 0 && strings.HasPrefix(s, me.prefix)) ||
+		(len(me.suffix) > 0 && strings.HasSuffix(s, me.suffix)) ||
+		(len(me.contains) > 0 && strings.Contains(s, me.contains))
 }
 
 //	Matches a string against "simple-patterns": patterns that can have asterisk (*) wildcards only
@@ -23,12 +39,18 @@ type matcherPattern struct {
 //	But I found that in a big portion of pattern-matching use-cases, I'm just doing "begins-or-ends-or-contains-or-equals" testing.
 //	Hence the conception of the "simple-pattern".
 //
+//	All other patterns are globs (see `GlobRegexp` for the syntax), compiled once when added. Patterns beginning
+//	with `!` are negations: as with `.gitignore` files, the last of the patterns to match a value decides, so
+//	`AddPatterns("**/*.go", "!vendor/**")` matches all `.go` files except those under `vendor`.
+//	(Globs that fail to compile only match values equal to them.)
+//
 //	There is also an alternative `Pattern` type in this package. Use `Matcher` to match strings against multiple patterns
 //	at once, especially if the patterns don't change often and the matchings occur frequently / repeatedly.
 //	In simpler, rarer one-off matchings, `Pattern` is preferable for simpler "setup-less" matching.
 type Matcher struct {
 	patterns     []matcherPattern
 	hasWildcards bool
+	hasNegations bool
 }
 
 //	Adds the specified simple-`patterns` to me.
@@ -36,9 +58,13 @@ func (me *Matcher) AddPatterns(patterns ...string) {
 	var s string
 	patts := make([]matcherPattern, len(patterns))
 	for i := 0; i < len(patterns); i++ {
-		s = patterns[i]
-		if patts[i].pattern, patts[i].any = s, len(s) == 0 || s == "*"; !patts[i].any {
-			if strings.HasPrefix(s, "*") && strings.HasSuffix(s, "*") {
+		if s = patterns[i]; strings.HasPrefix(s, "!") {
+			patts[i].negated, me.hasNegations, s = true, true, s[1:]
+		}
+		if patts[i].pattern, patts[i].any = s, len(s) == 0 || s == "*" || s == "**"; !patts[i].any {
+			if !isSimplePattern(s) {
+				patts[i].glob, _ = GlobRegexp(s)
+			} else if strings.HasPrefix(s, "*") && strings.HasSuffix(s, "*") {
 				patts[i].contains = s[1 : len(s)-1]
 			} else if strings.HasPrefix(s, "*") {
 				patts[i].suffix = s[1:]
@@ -46,7 +72,7 @@ func (me *Matcher) AddPatterns(patterns ...string) {
 				patts[i].prefix = s[:len(s)-1]
 			}
 		}
-		if patts[i].any || len(patts[i].contains) > 0 || len(patts[i].prefix) > 0 || len(patts[i].suffix) > 0 {
+		if patts[i].any || patts[i].glob != nil || len(patts[i].contains) > 0 || len(patts[i].prefix) > 0 || len(patts[i].suffix) > 0 {
 			me.hasWildcards = true
 		}
 	}
@@ -60,27 +86,28 @@ func (me *Matcher) HasWildcardPatterns() bool {
 
 //	Matches `s` against all patterns in `me`.
 func (me *Matcher) IsMatch(s string) bool {
-	for i := 0; i < len(me.patterns); i++ {
-		if me.patterns[i].any || s == me.patterns[i].pattern {
-			return true
-		}
-		if me.hasWildcards {
-			if len(me.patterns[i].prefix) > 0 && strings.HasPrefix(s, me.patterns[i].prefix) {
-				return true
-			}
-			if len(me.patterns[i].suffix) > 0 && strings.HasSuffix(s, me.patterns[i].suffix) {
-				return true
-			}
-			if len(me.patterns[i].contains) > 0 && strings.Contains(s, me.patterns[i].contains) {
+	if !me.hasNegations {
+		for i := 0; i < len(me.patterns); i++ {
+			if me.patterns[i].isMatch(s) {
 				return true
 			}
 		}
+		return false
 	}
-	return false
+	//	with negations, the last matching pattern decides --- and if the first pattern is a negation, all else is included
+	for i := len(me.patterns) - 1; i >= 0; i-- {
+		if me.patterns[i].isMatch(s) {
+			return !me.patterns[i].negated
+		}
+	}
+	return len(me.patterns) > 0 && me.patterns[0].negated
 }
 
 //	An "leaner" alternative to `Matcher` (see docs for `Matcher`). This represents a
 //	single "simple-pattern" and provides matching methods for one or multiple values.
+//
+//	A `Pattern` may also be a glob or a `!`-negation, as described for `Matcher`. (Their
+//	compiled forms are cached, so that repeated matching only compiles once per pattern.)
 type Pattern string
 
 //	Returns whether all specified `values` match this simple-pattern.
@@ -112,12 +139,18 @@ func (me Pattern) AnyMatches(values ...string) (firstMatch string) {
 //	Returns whether the specified `value` matches this simple-pattern.
 func (me Pattern) IsMatch(value string) bool {
 	meLen := len(me)
-	if meLen == 0 || me == "*" {
+	if meLen == 0 || me == "*" || me == "**" {
 		return true
 	}
+	if me[0] == '!' {
+		return !me[1:].IsMatch(value)
+	}
+	if !isSimplePattern(string(me)) {
+		return patternGlob(string(me)).isMatch(value)
+	}
 	prefix, suffix := me[0] == '*', me[meLen-1] == '*'
 	if prefix && suffix {
-		return strings.Contains(value, string(me)[1:meLen-2])
+		return strings.Contains(value, string(me)[1:meLen-1])
 	} else if prefix {
 		return strings.HasSuffix(value, string(me)[1:])
 	} else if suffix {
@@ -125,3 +158,162 @@ func (me Pattern) IsMatch(value string) bool {
 	}
 	return value == string(me)
 }
+
+var patternGlobs struct {
+	sync.RWMutex
+	m map[string]*matcherPattern
+}
+
+func patternGlob(pattern string) (patt *matcherPattern) {
+	patternGlobs.RLock()
+	patt = patternGlobs.m[pattern]
+	patternGlobs.RUnlock()
+	if patt == nil {
+		patt = &matcherPattern{pattern: pattern}
+		patt.glob, _ = GlobRegexp(pattern)
+		patternGlobs.Lock()
+		if patternGlobs.m == nil {
+			patternGlobs.m = map[string]*matcherPattern{}
+		}
+		patternGlobs.m[pattern] = patt
+		patternGlobs.Unlock()
+	}
+	return
+}
+
+//	Returns whether `pattern` is a "simple-pattern" (see `Matcher`) rather than a glob.
+func isSimplePattern(pattern string) bool {
+	if strings.ContainsAny(pattern, "?[]{}\\") || strings.Contains(pattern, "**") {
+		return false
+	}
+	if i := strings.IndexByte(pattern, '*'); i > 0 && i < len(pattern)-1 {
+		return false
+	} else if i == 0 && len(pattern) > 2 && strings.IndexByte(pattern[1:len(pattern)-1], '*') >= 0 {
+		return false
+	}
+	return true
+}
+
+//	Compiles the specified glob `pattern` into an (anchored) `regexp.Regexp`. The syntax:
+//
+//	`*` matches any sequence of non-`/` characters, `?` any single non-`/` character.
+//
+//	`**` matches any sequence of characters including `/`, so `**/` also matches zero
+//	directories (`**/*.go` matches `a.go` and `a/b/c.go`) and `vendor/**` matches all in `vendor`.
+//
+//	`[abc]`, `[a-z0-9]` match one of the given characters (not `/`), `[!abc]` or `[^abc]` one of any other.
+//
+//	`{go,mod}` matches any one of the comma-separated alternatives, which may in turn contain globs.
+//
+//	`\` escapes the following character, so that `\*` matches a literal `*`.
+//
+//	An `error` is returned for unterminated `[` or `{`.
+func GlobRegexp(pattern string) (*regexp.Regexp, error) {
+	var buf strings.Builder
+	buf.WriteString("^")
+	braces := 0
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i < len(pattern)-1 && pattern[i+1] == '*' {
+				if i += 1; i < len(pattern)-1 && pattern[i+1] == '/' {
+					buf.WriteString("(?:.*/)?")
+					i++
+				} else {
+					buf.WriteString(".*")
+				}
+			} else {
+				buf.WriteString("[^/]*")
+			}
+		case '?':
+			buf.WriteString("[^/]")
+		case '[':
+			end := i + 1
+			if end < len(pattern) && (pattern[end] == '!' || pattern[end] == '^') {
+				end++
+			}
+			if end < len(pattern) && pattern[end] == ']' {
+				end++
+			}
+			for end < len(pattern) && pattern[end] != ']' {
+				end++
+			}
+			if end >= len(pattern) {
+				return nil, &syntax.Error{Code: syntax.ErrMissingBracket, Expr: pattern}
+			}
+			class := pattern[i+1 : end]
+			negated := len(class) > 0 && (class[0] == '!' || class[0] == '^')
+			if negated {
+				class = class[1:]
+			}
+			buf.WriteString("[")
+			if negated {
+				buf.WriteString("^/")
+			}
+			classlen := buf.Len()
+			for j, runes := 0, []rune(class); j < len(runes); j++ {
+				lo, hi := runes[j], runes[j]
+				if j < len(runes)-2 && runes[j+1] == '-' {
+					hi, j = runes[j+2], j+2
+				}
+				if negated || lo > '/' || hi < '/' {
+					globClassRange(&buf, lo, hi)
+				} else { // leave out `/`, which a range such as `+-9` would otherwise include
+					if lo < '/' {
+						globClassRange(&buf, lo, '/'-1)
+					}
+					if hi > '/' {
+						globClassRange(&buf, '/'+1, hi)
+					}
+				}
+			}
+			if buf.Len() == classlen && !negated { // such as `[/]`: matches nothing
+				buf.WriteString(`^\x00-\x{10FFFF}`)
+			}
+			buf.WriteString("]")
+			i = end
+		case '{':
+			braces++
+			buf.WriteString("(?:")
+		case '}':
+			if braces > 0 {
+				braces--
+				buf.WriteString(")")
+			} else {
+				buf.WriteString(`\}`)
+			}
+		case ',':
+			if braces > 0 {
+				buf.WriteString("|")
+			} else {
+				buf.WriteString(",")
+			}
+		case '\\':
+			if i < len(pattern)-1 {
+				i++
+			}
+			if pattern[i] < 0x80 {
+				buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			} else {
+				buf.WriteByte(pattern[i])
+			}
+		default:
+			if c < 0x80 {
+				buf.WriteString(regexp.QuoteMeta(string(c)))
+			} else { // part of a multi-byte UTF-8 sequence, copied over as-is
+				buf.WriteByte(c)
+			}
+		}
+	}
+	if braces > 0 {
+		return nil, &syntax.Error{Code: syntax.ErrMissingParen, Expr: pattern}
+	}
+	buf.WriteString("$")
+	return regexp.Compile(buf.String())
+}
+
+func globClassRange(buf *strings.Builder, lo rune, hi rune) {
+	if fmt.Fprintf(buf, `\x{%x}`, lo); hi != lo {
+		fmt.Fprintf(buf, `-\x{%x}`, hi)
+	}
+}
@@ -69,6 +69,7 @@ func MapEq(m1, m2 map[string]string) bool {
 	return true
 }
 
+//	Appends spaces to `s` up to a byte length of `ensurelen`. For terminal display widths, see `PadRightToWidth`.
 func PadRight(s string, ensurelen int) string {
 	if numspaces := ensurelen - len(s); numspaces > 0 {
 		return s + strings.Repeat(" ", numspaces)
@@ -76,6 +77,7 @@ func PadRight(s string, ensurelen int) string {
 	return s
 }
 
+//	Returns the greatest byte length among `vals`. For terminal display widths, see `LongestWidth`.
 func Longest(vals ...string) (maxlen int) {
 	for _, str := range vals {
 		if l := len(str); l > maxlen {
@@ -315,20 +317,14 @@ func HasOnce(str1, str2 string) bool {
 	return (first >= 0) && (first == last)
 }
 
-//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`. Equivalent to the generic `umisc.If`.
 func Ifm(cond bool, ifTrue, ifFalse map[string]string) map[string]string {
-	if cond {
-		return ifTrue
-	}
-	return ifFalse
+	return umisc.If(cond, ifTrue, ifFalse)
 }
 
-//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`.
+//	Returns `ifTrue` if `cond` is `true`, otherwise returns `ifFalse`. Equivalent to the generic `umisc.If`.
 func Ifs(cond bool, ifTrue, ifFalse string) string {
-	if cond {
-		return ifTrue
-	}
-	return ifFalse
+	return umisc.If(cond, ifTrue, ifFalse)
 }
 
 //	For all `seps`, records its position of first occurrence in `s`, then returns the smallest such position.
@@ -514,6 +510,7 @@ func Replace(str string, repls map[string]string) string {
 }
 
 //	Creates a Pascal-cased "identifier" version of the specified string.
+//	For other naming styles (or acronym-aware `Words` splitting), see `SafeIdentifierAs`.
 func SafeIdentifier(s string) string {
 	var (
 		isL, isD, last bool
@@ -539,6 +536,22 @@ func SafeIdentifier(s string) string {
 	return strings.Join(words, "")
 }
 
+//	Returns `toCase(s)` (such as `ToGoPascalCase` or `ToSnakeCase`) with all runes other than
+//	letters, digits and `_` removed, prefixed with `_` if it would otherwise begin with a digit.
+//	For the legacy Pascal-casing (that leaves mixed-case words alone), see `SafeIdentifier`.
+func SafeIdentifierAs(s string, toCase func(string) string) string {
+	ident := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return -1
+	}, toCase(s))
+	if unicode.IsDigit(FirstRune(ident)) {
+		ident = "_" + ident
+	}
+	return ident
+}
+
 //	Returns an empty slice is `v` is emtpy, otherwise like `strings.Split`
 func Split(v, sep string) (sl []string) {
 	if len(v) > 0 {
@@ -0,0 +1,137 @@
+package ustr
+
+import (
+	"io"
+	"strings"
+)
+
+//	Formats rows of cells into aligned columns of text for terminal output, measuring cells by their display
+//	`Width` (so that CJK text, emoji and combining marks don't throw off the alignment). Cells may contain
+//	line breaks, in which case their row spans multiple lines. Tabs in cells are expanded to 4 spaces.
+type TextTable struct {
+	//	If set, written before all `Rows`, underlined with `HeaderLine` if that's not 0.
+	Header []string
+
+	//	Appended to by `AddRow`. Rows may have different numbers of cells.
+	Rows [][]string
+
+	//	The rune repeated to underline `Header`, such as `-` or `─`. 0 for no underline.
+	HeaderLine rune
+
+	//	Written between columns, defaults to 2 spaces if empty.
+	ColSep string
+
+	//	Per-column (optional): whether to right-align rather than left-align the column's cells.
+	AlignRight []bool
+
+	//	Per-column (optional): the maximum display width of the column, 0 for no maximum.
+	//	Cell lines exceeding it are word-wrapped if `Wrap`, otherwise truncated with `Ellipsis`.
+	MaxWidths []int
+
+	//	Whether to word-wrap (via `WrapToWidth`) rather than truncate (via `TruncateToWidth`) overly wide cells.
+	Wrap bool
+
+	//	Appended to truncated cell lines, defaults to `…` if empty.
+	Ellipsis string
+}
+
+//	Appends a new row with the specified `cells` to `me.Rows`.
+func (me *TextTable) AddRow(cells ...string) {
+	me.Rows = append(me.Rows, cells)
+}
+
+//	Returns the formatted table, each line (including the last) terminated by `\n` and without trailing spaces.
+func (me *TextTable) String() string {
+	var buf strings.Builder
+	me.WriteTo(&buf)
+	return buf.String()
+}
+
+//	Writes the formatted table (as returned by `String`) to `w`. Implements `io.WriterTo`.
+func (me *TextTable) WriteTo(w io.Writer) (int64, error) {
+	colsep, ellipsis := me.ColSep, me.Ellipsis
+	if len(colsep) == 0 {
+		colsep = "  "
+	}
+	if len(ellipsis) == 0 {
+		ellipsis = "…"
+	}
+
+	rows := me.Rows
+	if len(me.Header) > 0 {
+		rows = append([][]string{me.Header}, rows...)
+	}
+	var colwidths []int
+	cells := make([][][]string, len(rows)) // row, col, line
+	for r, row := range rows {
+		cells[r] = make([][]string, len(row))
+		for c, cell := range row {
+			if c >= len(colwidths) {
+				colwidths = append(colwidths, 0)
+			}
+			maxwidth := 0
+			if c < len(me.MaxWidths) {
+				maxwidth = me.MaxWidths[c]
+			}
+			for _, ln := range strings.Split(strings.Replace(strings.Replace(cell, "\r\n", "\n", -1), "\t", "    ", -1), "\n") {
+				if lnwidth := Width(ln); maxwidth > 0 && lnwidth > maxwidth && me.Wrap {
+					cells[r][c] = append(cells[r][c], WrapToWidth(ln, maxwidth)...)
+				} else if maxwidth > 0 && lnwidth > maxwidth {
+					cells[r][c] = append(cells[r][c], TruncateToWidth(ln, maxwidth, ellipsis))
+				} else {
+					cells[r][c] = append(cells[r][c], ln)
+				}
+			}
+			for _, ln := range cells[r][c] {
+				if lnwidth := Width(ln); lnwidth > colwidths[c] {
+					colwidths[c] = lnwidth
+				}
+			}
+		}
+	}
+
+	var buf strings.Builder
+	writeln := func(ln string) {
+		buf.WriteString(strings.TrimRight(ln, " "))
+		buf.WriteByte('\n')
+	}
+	for r := range cells {
+		numlines := 1
+		for c := range cells[r] {
+			if n := len(cells[r][c]); n > numlines {
+				numlines = n
+			}
+		}
+		for l := 0; l < numlines; l++ {
+			var ln strings.Builder
+			for c := range cells[r] {
+				if c > 0 {
+					ln.WriteString(colsep)
+				}
+				var s string
+				if l < len(cells[r][c]) {
+					s = cells[r][c][l]
+				}
+				if c < len(me.AlignRight) && me.AlignRight[c] {
+					ln.WriteString(PadLeftToWidth(s, colwidths[c]))
+				} else {
+					ln.WriteString(PadRightToWidth(s, colwidths[c]))
+				}
+			}
+			writeln(ln.String())
+		}
+		if r == 0 && len(me.Header) > 0 && me.HeaderLine != 0 {
+			underline := 0
+			for c := range colwidths {
+				underline += colwidths[c]
+			}
+			underline += (len(colwidths) - 1) * Width(colsep)
+			if w := Width(string(me.HeaderLine)); w > 1 { // such as for East Asian wide runes
+				underline /= w
+			}
+			writeln(strings.Repeat(string(me.HeaderLine), underline))
+		}
+	}
+	n, err := io.WriteString(w, buf.String())
+	return int64(n), err
+}
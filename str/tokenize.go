@@ -0,0 +1,237 @@
+package ustr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//	A streaming tokenizer in the manner of `bufio.Scanner`: splits a string on separators, but not on those
+//	inside quotes, escaped ones, or (if `Brackets` are set) those inside nested brackets. The zero value splits
+//	on white-space like `strings.Fields`. Configure the exported fields, then call `Reset` and loop over `Next`:
+//
+//		tok := ustr.Tokenizer{Seps: ",", Quotes: `"`, Escape: '\\', Brackets: "()[]{}", Trim: " "}
+//		for tok.Reset(src); tok.Next(); {
+//			key, val, _ := tok.Cut(tok.Token(), '=')
+//			// ...
+//		}
+//		if err := tok.Err(); err != nil { /* ... */ }
+//
+//	`Token` and `Value` are sub-strings of the source (and thus don't allocate) except where
+//	`Value` needs to remove quotes and escapes. For POSIX shell words, see `NewShellTokenizer`.
+type Tokenizer struct {
+	//	The separator runes. If empty, all `unicode.IsSpace` runes separate.
+	Seps string
+
+	//	The quote runes: separators between a quote rune and its next occurrence don't split.
+	Quotes string
+
+	//	Those of the `Quotes` inside of which `Escape` has no effect (such as `'` in shells).
+	RawQuotes string
+
+	//	If not 0, the rune that escapes the following rune (as a separator, quote or bracket).
+	Escape rune
+
+	//	Pairs of opening and closing bracket runes, such as `"()[]{}"`: separators inside brackets
+	//	don't split. Brackets must be balanced and properly nested (other than inside quotes).
+	Brackets string
+
+	//	If not empty, the cut-set `strings.Trim`med from both ends of every token.
+	Trim string
+
+	//	If `false`, runs of separators count as one and empty tokens are skipped (as in `strings.Fields`).
+	//	If `true`, every separator separates and empty tokens are kept (as in `strings.Split`).
+	KeepEmpty bool
+
+	//	If set, used by `Next` to obtain `Value` from `Token`, otherwise a default that
+	//	removes all `Quotes` and un-escapes all `Escape`d runes (other than inside `RawQuotes`).
+	Unquote func(token string) (string, error)
+
+	src     string
+	pos     int
+	done    bool
+	tok     string
+	val     string
+	err     error
+	closers []rune
+}
+
+//	Returns a `Tokenizer` splitting `src` into shell words as per POSIX rules: words are separated by
+//	unquoted blanks and newlines, single quotes quote everything literally, double quotes allow
+//	`\`-escaping of `$`, backtick, `"`, `\` and newline, and elsewhere `\` escapes any character.
+//	`Value`s are the words as `UnquoteShell` returns them: expansions, globs and operators are
+//	rejected with an error (as no actual shell is involved), not interpreted.
+func NewShellTokenizer(src string) *Tokenizer {
+	tok := &Tokenizer{Seps: " \t\n", Quotes: `"'`, RawQuotes: `'`, Escape: '\\', Unquote: UnquoteShell}
+	tok.Reset(src)
+	return tok
+}
+
+//	Returns the shell words in `s`, as per `NewShellTokenizer`. For example,
+//	`grep -e 'a b' "c \"d\"" e\ f` becomes `["grep", "-e", "a b", "c \"d\"", "e f"]`.
+func SplitShellWords(s string) (words []string, err error) {
+	return NewShellTokenizer(s).All()
+}
+
+//	Restarts `me` on `src`, clearing any previous `Err`.
+func (me *Tokenizer) Reset(src string) {
+	me.src, me.pos, me.done, me.tok, me.val, me.err = src, 0, len(src) == 0, "", "", nil
+}
+
+//	Advances to the next token, returning `false` at the end of the source or on error (then see `Err`).
+func (me *Tokenizer) Next() bool {
+	for !me.done && me.err == nil {
+		start, sepsize := me.pos, 0
+		if me.pos, sepsize, me.err = me.scan(me.src, me.pos, 0); me.err != nil {
+			break
+		}
+		me.tok = me.src[start:me.pos]
+		if me.pos += sepsize; sepsize == 0 {
+			me.done = true
+		}
+		if len(me.Trim) > 0 {
+			me.tok = strings.Trim(me.tok, me.Trim)
+		}
+		if len(me.tok) == 0 && !me.KeepEmpty {
+			continue
+		}
+		if me.Unquote != nil {
+			me.val, me.err = me.Unquote(me.tok)
+		} else {
+			me.val = me.unquote(me.tok)
+		}
+		return me.err == nil
+	}
+	me.tok, me.val = "", ""
+	return false
+}
+
+//	Returns the first error encountered by `Next`, if any: an unterminated quote,
+//	an unbalanced bracket, a trailing `Escape` or an error returned by `Unquote`.
+func (me *Tokenizer) Err() error {
+	return me.err
+}
+
+//	Returns the current token as it occurs in the source (but `Trim`med), with its quotes and escapes.
+func (me *Tokenizer) Token() string {
+	return me.tok
+}
+
+//	Returns the current token without its quotes and escapes (see `Unquote`).
+func (me *Tokenizer) Value() string {
+	return me.val
+}
+
+//	Returns the byte offset into the source following the current token (and its separator, if any).
+func (me *Tokenizer) Pos() int {
+	return me.pos
+}
+
+//	Collects the `Value`s of all remaining tokens.
+func (me *Tokenizer) All() (vals []string, err error) {
+	for me.Next() {
+		vals = append(vals, me.Value())
+	}
+	return vals, me.Err()
+}
+
+//	Like `SplitOnce` (or `strings.Cut`), but finds the first `sep` in `s` that isn't quoted, escaped or inside
+//	brackets (as per `me`'s settings, other than `Seps`). For malformed `s` (such as with an unterminated
+//	quote), `found` is `false`. Useful for splitting `key=value` tokens, with `Value` semantics left to the caller.
+func (me *Tokenizer) Cut(s string, sep rune) (before string, after string, found bool) {
+	if i, sepsize, err := me.scan(s, 0, sep); err == nil && sepsize > 0 {
+		return s[:i], s[i+sepsize:], true
+	}
+	return s, "", false
+}
+
+func (me *Tokenizer) isSep(r rune) bool {
+	if len(me.Seps) == 0 {
+		return unicode.IsSpace(r)
+	}
+	return strings.IndexRune(me.Seps, r) >= 0
+}
+
+//	Returns the byte offset of the first separator (or `sep` if not 0) from offset `i` in `s`
+//	that isn't quoted, escaped or bracketed, and its size, which is 0 if there's none.
+func (me *Tokenizer) scan(s string, i int, sep rune) (end int, sepsize int, err error) {
+	quote, quotepos, closers := rune(0), 0, me.closers[:0]
+	defer func() { me.closers = closers }()
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == me.Escape && me.Escape != 0 && strings.IndexRune(me.RawQuotes, quote) < 0 && i+size < len(s) {
+				_, esize := utf8.DecodeRuneInString(s[i+size:])
+				size += esize
+			}
+		case r == me.Escape && me.Escape != 0:
+			if i+size >= len(s) {
+				return i, 0, fmt.Errorf("offset %d: incomplete escape", i)
+			}
+			_, esize := utf8.DecodeRuneInString(s[i+size:])
+			size += esize
+		case strings.IndexRune(me.Quotes, r) >= 0:
+			quote, quotepos = r, i
+		case len(closers) == 0 && ((sep == 0 && me.isSep(r)) || (sep != 0 && r == sep)):
+			return i, size, nil
+		default:
+			if closer, isopen, isclose := me.bracket(r); isopen {
+				closers = append(closers, closer)
+			} else if l := len(closers) - 1; isclose && (l < 0 || closers[l] != r) {
+				return i, 0, fmt.Errorf("offset %d: unbalanced `%c`", i, r)
+			} else if isclose {
+				closers = closers[:l]
+			}
+		}
+		i += size
+	}
+	if quote != 0 {
+		return i, 0, fmt.Errorf("offset %d: unterminated `%c` quote", quotepos, quote)
+	} else if len(closers) > 0 {
+		return i, 0, fmt.Errorf("offset %d: missing `%c`", i, closers[len(closers)-1])
+	}
+	return i, 0, nil
+}
+
+//	Returns whether `r` is an opening (and then its `closer`) or a closing rune of the `Brackets`.
+func (me *Tokenizer) bracket(r rune) (closer rune, isOpen bool, isClose bool) {
+	for s := me.Brackets; len(s) > 0; {
+		open, size := utf8.DecodeRuneInString(s)
+		closing, csize := utf8.DecodeRuneInString(s[size:])
+		if r == open {
+			return closing, true, false
+		} else if r == closing {
+			return 0, false, true
+		}
+		s = s[size+csize:]
+	}
+	return
+}
+
+func (me *Tokenizer) unquote(tok string) string {
+	if (len(me.Quotes) == 0 || strings.IndexAny(tok, me.Quotes) < 0) && (me.Escape == 0 || strings.IndexRune(tok, me.Escape) < 0) {
+		return tok
+	}
+	var buf strings.Builder
+	quote, escaped := rune(0), false
+	for _, r := range tok {
+		switch {
+		case escaped:
+			buf.WriteRune(r)
+			escaped = false
+		case quote != 0 && r == quote:
+			quote = 0
+		case r == me.Escape && me.Escape != 0 && (quote == 0 || strings.IndexRune(me.RawQuotes, quote) < 0):
+			escaped = true
+		case quote == 0 && strings.IndexRune(me.Quotes, r) >= 0:
+			quote = r
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,208 @@
+package ustr
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//	East Asian Wide and Fullwidth code points, plus emoji that default to emoji presentation.
+var widthWideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC}, {0x23F0, 0x23F0}, {0x23F3, 0x23F3},
+	{0x25FD, 0x25FE}, {0x2614, 0x2615}, {0x2648, 0x2653}, {0x267F, 0x267F}, {0x2693, 0x2693}, {0x26A1, 0x26A1},
+	{0x26AA, 0x26AB}, {0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26CE, 0x26CE}, {0x26D4, 0x26D4}, {0x26EA, 0x26EA},
+	{0x26F2, 0x26F3}, {0x26F5, 0x26F5}, {0x26FA, 0x26FA}, {0x26FD, 0x26FD}, {0x2705, 0x2705}, {0x270A, 0x270B},
+	{0x2728, 0x2728}, {0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755}, {0x2757, 0x2757}, {0x2795, 0x2797},
+	{0x27B0, 0x27B0}, {0x27BF, 0x27BF}, {0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x2E80, 0x303E},
+	{0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF}, {0xA960, 0xA97F}, {0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF}, {0xFE10, 0xFE19}, {0xFE30, 0xFE6F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6},
+	{0x16FE0, 0x16FE4}, {0x17000, 0x18AFF}, {0x1B000, 0x1B2FF}, {0x1F004, 0x1F004}, {0x1F0CF, 0x1F0CF},
+	{0x1F18E, 0x1F18E}, {0x1F191, 0x1F19A}, {0x1F200, 0x1F251}, {0x1F260, 0x1F265}, {0x1F300, 0x1F320},
+	{0x1F32D, 0x1F335}, {0x1F337, 0x1F37C}, {0x1F37E, 0x1F393}, {0x1F3A0, 0x1F3CA}, {0x1F3CF, 0x1F3D3},
+	{0x1F3E0, 0x1F3F0}, {0x1F3F4, 0x1F3F4}, {0x1F3F8, 0x1F43E}, {0x1F440, 0x1F440}, {0x1F442, 0x1F4FC},
+	{0x1F4FF, 0x1F53D}, {0x1F54B, 0x1F54E}, {0x1F550, 0x1F567}, {0x1F57A, 0x1F57A}, {0x1F595, 0x1F596},
+	{0x1F5A4, 0x1F5A4}, {0x1F5FB, 0x1F64F}, {0x1F680, 0x1F6C5}, {0x1F6CC, 0x1F6CC}, {0x1F6D0, 0x1F6D2},
+	{0x1F6D5, 0x1F6D7}, {0x1F6EB, 0x1F6EC}, {0x1F6F4, 0x1F6FC}, {0x1F7E0, 0x1F7EB}, {0x1F90C, 0x1F93A},
+	{0x1F93C, 0x1F945}, {0x1F947, 0x1F9FF}, {0x1FA70, 0x1FAFF}, {0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+//	Returns the number of terminal columns that `r` occupies on its own: 0 for control characters,
+//	combining marks and other zero-width runes, 2 for East Asian wide / full-width runes and most emoji,
+//	otherwise 1. Tabs are control characters, so expand them first if need be.
+func RuneWidth(r rune) int {
+	switch {
+	case r < 0x20 || (r >= 0x7F && r < 0xA0):
+		return 0
+	case r < 0x300:
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) || (r >= 0x1160 && r <= 0x11FF):
+		return 0
+	case r < 0x1100:
+		return 1
+	}
+	i := sort.Search(len(widthWideRanges), func(i int) bool { return widthWideRanges[i].hi >= r })
+	if i < len(widthWideRanges) && r >= widthWideRanges[i].lo {
+		return 2
+	}
+	return 1
+}
+
+func widthIsRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+//	Returns whether `r` continues rather than begins a grapheme cluster (no matter what precedes it).
+func widthIsGraphemeExtend(r rune) bool {
+	return r >= 0x300 && (unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == 0x200C || r == 0x200D || // zero-width (non-)joiner
+		(r >= 0x1F3FB && r <= 0x1F3FF) || // emoji skin-tone modifiers
+		(r >= 0xE0020 && r <= 0xE007F)) // emoji tag sequences
+}
+
+//	Returns the first grapheme cluster in `s` (a "user-perceived character") and its display `width`.
+//	Handled are combining marks, emoji variation selectors, modifiers, tag and ZWJ sequences, regional-indicator
+//	flag pairs and CRLF --- that is, the clusters that matter for display width, not all the UAX #29 rules.
+func graphemeNext(s string) (cluster string, width int) {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return
+	}
+	first, numri, joined := r, 0, false
+	if width = RuneWidth(r); widthIsRegionalIndicator(r) {
+		numri = 1
+	}
+	if r == '\r' && len(s) > 1 && s[1] == '\n' {
+		return s[:2], 0
+	}
+loop:
+	for len(s) > size {
+		r, rsize := utf8.DecodeRuneInString(s[size:])
+		switch {
+		case r == 0xFE0F: // emoji presentation selector, also for keycaps like `1️⃣`
+			if width == 1 && (first > 0x7F || first == '#' || first == '*' || (first >= '0' && first <= '9')) {
+				width = 2
+			}
+		case widthIsGraphemeExtend(r):
+			joined = r == 0x200D
+		case joined:
+			joined = false
+		case numri == 1 && widthIsRegionalIndicator(r):
+			numri, width = 2, 2
+		default:
+			break loop
+		}
+		size += rsize
+	}
+	return s[:size], width
+}
+
+//	Returns the grapheme clusters ("user-perceived characters") in `s`, such as a letter followed by
+//	its combining accents, or an emoji sequence. (Not all of the UAX #29 rules are implemented, but
+//	the ones relevant for display widths are.)
+func Graphemes(s string) (clusters []string) {
+	for len(s) > 0 {
+		cluster, _ := graphemeNext(s)
+		clusters, s = append(clusters, cluster), s[len(cluster):]
+	}
+	return
+}
+
+//	Returns the number of terminal columns that `s` occupies (if it contains no line breaks):
+//	unlike `len` or `utf8.RuneCountInString`, this accounts for East Asian wide runes,
+//	zero-width runes, combining marks and multi-rune emoji sequences (see `RuneWidth`).
+func Width(s string) (width int) {
+	for i := 0; i < len(s); {
+		if c := s[i]; c >= 0x20 && c < 0x7F && (i == len(s)-1 || s[i+1] < 0x80) {
+			width, i = width+1, i+1 // fast path for ASCII
+		} else {
+			cluster, w := graphemeNext(s[i:])
+			width, i = width+w, i+len(cluster)
+		}
+	}
+	return
+}
+
+//	Like `Longest`, but returns the greatest `Width` rather than byte length.
+func LongestWidth(vals ...string) (maxWidth int) {
+	for _, str := range vals {
+		if w := Width(str); w > maxWidth {
+			maxWidth = w
+		}
+	}
+	return
+}
+
+//	Like `PadRight`, but pads `s` with spaces to the specified display `width` (as per `Width`).
+func PadRightToWidth(s string, width int) string {
+	if numspaces := width - Width(s); numspaces > 0 {
+		return s + strings.Repeat(" ", numspaces)
+	}
+	return s
+}
+
+//	Prepends spaces to `s` to pad it to the specified display `width` (as per `Width`).
+func PadLeftToWidth(s string, width int) string {
+	if numspaces := width - Width(s); numspaces > 0 {
+		return strings.Repeat(" ", numspaces) + s
+	}
+	return s
+}
+
+//	Returns `s` if its `Width` doesn't exceed `width`, otherwise as many of its leading grapheme clusters
+//	as fit into `width` together with the appended `ellipsis` (such as `"…"` or `"..."`).
+func TruncateToWidth(s string, width int, ellipsis string) string {
+	if Width(s) <= width {
+		return s
+	}
+	maxwidth, curwidth, end := width-Width(ellipsis), 0, 0
+	if maxwidth < 0 {
+		return ""
+	}
+	for end < len(s) {
+		cluster, w := graphemeNext(s[end:])
+		if curwidth+w > maxwidth {
+			break
+		}
+		curwidth, end = curwidth+w, end+len(cluster)
+	}
+	return s[:end] + ellipsis
+}
+
+//	Word-wraps `s` into lines of at most `width` display columns (as per `Width`). Existing line breaks are
+//	kept, runs of spaces between words collapse to a single space, and words wider than `width` are split.
+func WrapToWidth(s string, width int) (lines []string) {
+	if width < 1 {
+		width = 1
+	}
+	for _, para := range strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n") {
+		var ln strings.Builder
+		lnwidth := 0
+		for _, word := range strings.Fields(para) {
+			wordwidth := Width(word)
+			if lnwidth > 0 && lnwidth+1+wordwidth > width {
+				lines, lnwidth = append(lines, ln.String()), 0
+				ln.Reset()
+			}
+			if lnwidth > 0 {
+				ln.WriteByte(' ')
+				lnwidth++
+			}
+			for wordwidth > width { // only ever with an empty `ln`, so split off full lines
+				end, w := 0, 0
+				for end < len(word) {
+					cluster, cw := graphemeNext(word[end:])
+					if w+cw > width && w > 0 {
+						break
+					}
+					end, w = end+len(cluster), w+cw
+				}
+				lines, word, wordwidth = append(lines, word[:end]), word[end:], wordwidth-w
+			}
+			ln.WriteString(word)
+			lnwidth += wordwidth
+		}
+		lines = append(lines, ln.String())
+	}
+	return
+}
@@ -71,6 +71,16 @@ func UserDataDirPath(preferCacheOverConfig bool) string {
 	return dirpath
 }
 
+//	Returns the path of the `ufs.FileStates` database file for `appName` under `UserDataDirPath(true)`.
+func FileStatesPath(appName string) string {
+	return filepath.Join(UserDataDirPath(true), appName, "filestates.json")
+}
+
+//	Short-hand for `ufs.OpenFileStates(FileStatesPath(appName))`.
+func OpenFileStates(appName string) (*ufs.FileStates, error) {
+	return ufs.OpenFileStates(FileStatesPath(appName))
+}
+
 //	Returns the path to the current user's home directory.
 func UserHomeDirPath() string {
 	dirpath := _userHomeDirPath